@@ -0,0 +1,121 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// access is what a feed needs to be fetched beyond what every feed gets,
+// set for it in the feeds file or the secrets file.
+type access struct {
+	userAgent string
+
+	// Credentials for HTTP Basic authentication or a bearer token.
+	username, password string
+	token              string
+
+	// cookie is sent in the Cookie header, along with any in the jar.
+	cookie string
+
+	// headers are set on every request, over any others.
+	headers http.Header
+
+	tls tlsOptions
+}
+
+// accesses holds the access of feeds that need one, by the URL the feed
+// is listed as.
+var accesses = struct {
+	sync.Mutex
+	byFeed map[string]access
+}{
+	byFeed: map[string]access{},
+}
+
+// accessFor is how to fetch the feed listed as u. Without its own
+// User-Agent, it gets -user-agent.
+func accessFor(u string) access {
+	accesses.Lock()
+	a := accesses.byFeed[u]
+	accesses.Unlock()
+	if a.userAgent == "" {
+		a.userAgent = *defaultUserAgent
+	}
+	if a.userAgent == "" {
+		// Say where this instance is, if it's public, so site owners can see
+		// who's polling them.
+		a.userAgent = "webrss/1.0 (+" + firstOf(*websubURL, "https://mccoy.space/g/webrss") + ")"
+	}
+	return a
+}
+
+// public is a without its credentials or TLS options, for requests to
+// other sites than the one they're for.
+func (a access) public() access {
+	return access{userAgent: a.userAgent}
+}
+
+// private reports whether a has anything meant only for the feed's host,
+// like credentials.
+func (a access) private() bool {
+	return a.username != "" || a.password != "" || a.token != "" || a.cookie != "" || len(a.headers) > 0
+}
+
+// client returns a client for making requests with a.
+func (a access) client() (*http.Client, error) {
+	c, err := newTLSClient(a.tls)
+	if err != nil {
+		return nil, err
+	}
+	c.CheckRedirect = a.redirects(nil)
+	return c, nil
+}
+
+// redirects makes a client's CheckRedirect, which follows redirects
+// according to check, or like the default one, if it's nil, but doesn't
+// send a's headers on to another host than the first request's.
+// The client already drops the Authorization and Cookie headers itself.
+func (a access) redirects(check func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			for name := range a.headers {
+				delete(req.Header, name)
+			}
+		}
+		if check != nil {
+			return check(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// apply sets up req according to a.
+func (a access) apply(req *http.Request) {
+	req.Header.Set("User-Agent", a.userAgent)
+	if a.cookie != "" {
+		req.Header.Set("Cookie", a.cookie)
+	}
+	switch {
+	case a.token != "":
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	case a.username != "" || a.password != "":
+		req.SetBasicAuth(a.username, a.password)
+	}
+	for name, values := range a.headers {
+		req.Header[name] = values
+	}
+}
+
+// sameSite reports whether the URL s is on the same host as u.
+func sameSite(s string, u *url.URL) bool {
+	su, err := url.Parse(s)
+	return err == nil && strings.EqualFold(su.Host, u.Host)
+}
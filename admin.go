@@ -0,0 +1,343 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var adminPassword = flag.String("admin-password", "", "Password for the admin pages, like /admin, asked for with HTTP Basic authentication; better given by $WEBRSS_ADMIN_PASSWORD, which ps can't show. Without one, they're only served if -http is a loopback address, like localhost:8080")
+
+// adminOnly serves h to those who give the -admin-password,
+// or, without one, if only this machine can connect, since -http
+// is a loopback address. Telling by the request's address isn't enough:
+// behind a reverse proxy, every request comes from this machine.
+// Posts from other sites are turned away, so they can't make
+// a browser that has the password change anything.
+func adminOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && crossSite(r) {
+			http.Error(w, "The admin pages can't be posted to from other sites.", http.StatusForbidden)
+			return
+		}
+		want := firstOf(*adminPassword, os.Getenv("WEBRSS_ADMIN_PASSWORD"))
+		if want == "" {
+			if !loopbackOnly() {
+				http.Error(w, "The admin pages need an -admin-password, unless -http is a loopback address.", http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+		_, got, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="webrss admin", charset="UTF-8"`)
+			http.Error(w, "That's not the password.", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// loopbackOnly is whether the server can only be connected to
+// from this machine: it's only listening on -http, which is a loopback address.
+func loopbackOnly() bool {
+	if *cert != "" && *key != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(*httpAddr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// crossSite is whether r was sent by a page on another site,
+// going by the Sec-Fetch-Site header browsers send, or by the Origin,
+// for those that don't. Requests with neither aren't from browsers.
+func crossSite(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+// feedsFileLock keeps the feeds file from being changed by more than one
+// thing at a time, like the admin page and a feed that's moved.
+var feedsFileLock sync.Mutex
+
+// A listedFeed is a feed as it's listed in the feeds file.
+type listedFeed struct {
+	URL   string
+	Title string
+	Group string
+	// Status is how fetching it has been going.
+	Status FeedStatus
+}
+
+// ID identifies the feed in links, like feed/ID.
+func (f listedFeed) ID() string {
+	return feedID(f.URL)
+}
+
+// listedFeeds is the feeds listed in the feeds file, in order.
+func listedFeeds() ([]listedFeed, error) {
+	b, err := readFile(*feeds)
+	if err != nil {
+		return nil, err
+	}
+	var listed []listedFeed
+	if isOPML(b) {
+		opml, err := parseOPML(b)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range opml {
+			listed = append(listed, listedFeed{URL: f.URL, Group: f.Group})
+		}
+	} else {
+		for _, l := range strings.Split(string(b), "\n") {
+			fields := splitQuoted(l)
+			if len(fields) == 0 {
+				continue
+			}
+			f := listedFeed{URL: fields[0]}
+			for _, s := range fields[1:] {
+				if v, ok := strings.CutPrefix(s, "title="); ok {
+					f.Title = v
+				} else if v, ok := strings.CutPrefix(s, "group="); ok {
+					f.Group = v
+				}
+			}
+			listed = append(listed, f)
+		}
+	}
+	statuses.Lock()
+	defer statuses.Unlock()
+	for i := range listed {
+		listed[i].Status = statuses.byFeed[listed[i].URL]
+	}
+	return listed, nil
+}
+
+// editFeedsFile replaces each line of the feeds file with what edit makes
+// of its fields, leaving out those it makes none of. Lines are made of
+// the fields again, quoted like splitQuoted expects. Blank lines are kept.
+func editFeedsFile(edit func(fields []string) []string) error {
+	b, err := readFile(*feeds)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if isOPML(b) {
+		return fmt.Errorf("%s is OPML, which I can read, but not change", *feeds)
+	}
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		fields := splitQuoted(l)
+		if len(fields) == 0 {
+			lines = append(lines, l)
+			continue
+		}
+		if fields = edit(fields); fields != nil {
+			lines = append(lines, joinQuoted(fields))
+		}
+	}
+	return saveFeedsFile([]byte(strings.Join(lines, "\n") + "\n"))
+}
+
+// joinQuoted joins fields with spaces, quoting those with spaces in them,
+// or just their values, if they're settings, like user-agent="A B".
+func joinQuoted(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		if !strings.ContainsAny(f, " \t") {
+			quoted[i] = f
+		} else if name, value, ok := strings.Cut(f, "="); ok && !strings.ContainsAny(name, " \t") {
+			quoted[i] = name + `="` + value + `"`
+		} else {
+			quoted[i] = `"` + f + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// setting is fields with the setting called name replaced with value,
+// or without it if value is empty.
+func setting(fields []string, name, value string) []string {
+	fields = slices.DeleteFunc(fields, func(f string) bool {
+		return strings.HasPrefix(f, name+"=")
+	})
+	value = strings.ReplaceAll(value, `"`, "")
+	if value != "" {
+		fields = append(fields, name+"="+value)
+	}
+	return fields
+}
+
+// adminHandler lists the feeds in the feeds file, and changes them as posted:
+// adding a feed, removing one, or giving one a title or group.
+// Feeds that are added or changed are fetched right away,
+// by sending them to refresh, which also re-reads the feeds file.
+func adminHandler(refresh chan<- string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *feeds == "" {
+			http.Error(w, "There's no -feeds file to change.", http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost {
+			u, err := changeFeed(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			select {
+			case refresh <- u:
+			case <-r.Context().Done():
+				return
+			}
+			http.Redirect(w, r, "admin", http.StatusSeeOther)
+			return
+		}
+
+		listed, err := listedFeeds()
+		if err != nil {
+			log.Printf("Couldn't list the feeds: %v\n", err)
+			http.Error(w, "Couldn't read the feeds file.", http.StatusInternalServerError)
+			return
+		}
+		adminPage.Execute(w, listed)
+	})
+}
+
+// changeFeed does what's posted to the admin page to the feed it's posted
+// for, returning its URL.
+func changeFeed(r *http.Request) (string, error) {
+	u := strings.TrimSpace(r.FormValue("feed"))
+	if u == "" || strings.ContainsAny(u, " \t\"") {
+		return "", errors.New("I need the URL of the feed, without spaces or quotes")
+	}
+	feedsFileLock.Lock()
+	defer feedsFileLock.Unlock()
+	listed, err := listedFeeds()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	have := slices.ContainsFunc(listed, func(f listedFeed) bool { return f.URL == u })
+
+	switch r.FormValue("do") {
+	case "add":
+		if have {
+			return "", fmt.Errorf("%s is already listed", u)
+		}
+		if p, err := url.Parse(u); err != nil || p.Scheme == "" {
+			return "", fmt.Errorf("%s isn't a URL", u)
+		}
+		fields := setting(setting([]string{u}, "title", r.FormValue("title")), "group", r.FormValue("group"))
+		err = appendFeedsFile(joinQuoted(fields))
+	case "remove":
+		err = editFeedsFile(func(f []string) []string {
+			if f[0] == u {
+				return nil
+			}
+			return f
+		})
+	case "edit":
+		if !have {
+			return "", fmt.Errorf("%s isn't listed", u)
+		}
+		err = editFeedsFile(func(f []string) []string {
+			if f[0] == u {
+				f = setting(setting(f, "title", r.FormValue("title")), "group", r.FormValue("group"))
+			}
+			return f
+		})
+	default:
+		return "", errors.New("I can only add, remove, or edit a feed")
+	}
+	if err != nil {
+		log.Printf("Couldn't change the feeds file: %v\n", err)
+		return "", err
+	}
+	return u, nil
+}
+
+// appendFeedsFile adds line to the end of the feeds file.
+func appendFeedsFile(line string) error {
+	b, err := readFile(*feeds)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if isOPML(b) {
+		return fmt.Errorf("%s is OPML, which I can read, but not change", *feeds)
+	}
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	return saveFeedsFile(append(b, line+"\n"...))
+}
+
+var adminPage = newPage("admin", adminPageTemplate)
+
+var adminPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Admin</title>
+</head>
+
+<body>
+		<nav class="details"><a href="./">today</a> · <a href="status">status</a> · <a href="import">import</a> · <form class="mark" method="post" action="admin/backup"><button class="details">back up</button></form></nav>
+		<div class="card">
+			<h1>Add a feed</h1>
+			<form class="search" method="post" action="admin">
+				<input type="hidden" name="do" value="add">
+				<input type="url" name="feed" placeholder="https://example.com/feed.xml" required>
+				<input type="text" name="title" placeholder="title">
+				<input type="text" name="group" placeholder="group">
+				<button>Add</button>
+			</form>
+		</div>
+		<ul>
+{{range .}}
+			<li class="card">
+				<h1><a href="feed/{{.ID}}">{{.URL}}</a></h1>
+				<p class="details">{{if not .Status.LastSuccess.IsZero}}last fetched {{.Status.LastSuccess.Format "2 Jan 15:04"}}{{else}}never fetched{{end}}{{with .Status.LastError}} · {{.}}{{end}}</p>
+				<form class="search" method="post" action="admin">
+					<input type="hidden" name="feed" value="{{.URL}}">
+					<input type="text" name="title" value="{{.Title}}" placeholder="title">
+					<input type="text" name="group" value="{{.Group}}" placeholder="group">
+					<button name="do" value="edit">Save</button>
+					<button name="do" value="remove">Remove</button>
+				</form>
+			</li>
+{{end}}
+		</ul>
+</body>
+</html>
+`
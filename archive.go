@@ -0,0 +1,253 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var archive = flag.Bool("archive", false, "Keep entries after their feeds drop them, until -keep or -keep-per-feed prunes them")
+
+// dayFormat is how days are given in links, like day?date=2021-06-01.
+const dayFormat = "2006-01-02"
+
+// monthFormat is how months are, like month?m=2021-06.
+const monthFormat = "2006-01"
+
+// carried is the entries that are kept when a fetch replaces them:
+// the starred ones, or with -archive, all of them.
+func carried(entries []Entry) []Entry {
+	if *archive {
+		return entries
+	}
+	return starred(entries)
+}
+
+// Prev is the day before the one shown. If what's shown is the last day
+// up to now, rather than a whole day, it's the whole day it started on.
+func (d Daily) Prev() string {
+	start := startOfDay(d.Day)
+	if start.Equal(d.Day) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start.Format(dayFormat)
+}
+
+// Next is the day after the one shown, or empty if that's still to come.
+func (d Daily) Next() string {
+	next := d.Day.AddDate(0, 0, 1)
+	if next.After(time.Now()) {
+		return ""
+	}
+	return next.Format(dayFormat)
+}
+
+// Month is the month of the day shown.
+func (d Daily) Month() string {
+	return d.Day.Format(monthFormat)
+}
+
+// showDay shows the day given in the path, like day/2021-06-01,
+// or as date, like day?date=2021-06-01.
+func showDay(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	date, base := r.FormValue("date"), ""
+	if d, ok := strings.CutPrefix(r.URL.Path, "/day/"); ok {
+		date, base = d, "../"
+	}
+	day, err := parseDay(date)
+	if err != nil {
+		http.Error(w, "The date must be like 2021-06-01.", http.StatusBadRequest)
+		return
+	}
+	if day.After(time.Now()) {
+		http.Error(w, "That day hasn't come yet.", http.StatusNotFound)
+		return
+	}
+	showDaily(w, day, dayView{base, "day/" + date, pageOf(r)}, fc)
+}
+
+// Month is how many entries there are on each day of a month.
+type Month struct {
+	Start time.Time
+	Days  []DayCount
+}
+
+// Weeks is the days of the month in rows for a calendar, Monday first,
+// with zero DayCounts before the first and after the last.
+func (m Month) Weeks() [][]DayCount {
+	var weeks [][]DayCount
+	week := make([]DayCount, (int(m.Start.Weekday())+6)%7)
+	for day := m.Start; day.Month() == m.Start.Month(); day = day.AddDate(0, 0, 1) {
+		dc := DayCount{Day: day}
+		if i := day.Day() - 1; i < len(m.Days) {
+			dc = m.Days[i]
+		}
+		week = append(week, dc)
+		if len(week) == 7 {
+			weeks = append(weeks, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		weeks = append(weeks, append(week, make([]DayCount, 7-len(week))...))
+	}
+	return weeks
+}
+
+// DayCount is how many entries there are on a day.
+type DayCount struct {
+	Day     time.Time
+	Entries int
+}
+
+// Future reports whether the day is still to come.
+func (d DayCount) Future() bool {
+	return d.Day.After(time.Now())
+}
+
+// Prev is the month before.
+func (m Month) Prev() string {
+	return m.Start.AddDate(0, -1, 0).Format(monthFormat)
+}
+
+// Next is the month after, or empty if that's still to come.
+func (m Month) Next() string {
+	next := m.Start.AddDate(0, 1, 0)
+	if next.After(time.Now()) {
+		return ""
+	}
+	return next.Format(monthFormat)
+}
+
+// showMonth shows a calendar of the month given as m, like 2021-06,
+// or this month, linking to its days.
+func showMonth(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	m := firstOf(r.FormValue("m"), startOfDay(time.Now().In(zone)).Format(monthFormat))
+	start, err := time.ParseInLocation(monthFormat, m, zone)
+	if err != nil {
+		http.Error(w, "The month must be like 2021-06.", http.StatusBadRequest)
+		return
+	}
+	start = dayOf(start)
+	feeds := <-fc
+
+	month := Month{Start: start}
+	for day := start; day.Month() == start.Month(); day = day.AddDate(0, 0, 1) {
+		if day.After(time.Now()) {
+			break
+		}
+		n := len(collapse(unmuted(filterEntries(feeds, day, day.AddDate(0, 0, 1)))))
+		month.Days = append(month.Days, DayCount{day, n})
+	}
+	monthPage.Execute(w, month)
+}
+
+// feedID identifies the feed listed as source in links, like feed/ID.
+func feedID(source string) string {
+	h := fnv.New64a()
+	h.Write([]byte(source))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// showFeed shows a page of the entries kept of a feed, newest first.
+// The feed is given in the path by its ID or name, like feed/ID,
+// or by the URL it's listed as, like feed?source=URL.
+func showFeed(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	source := r.FormValue("source")
+	name, byPath := strings.CutPrefix(r.URL.Path, "/feed/")
+	base, link := "", "feed/"+feedID(source)
+	if byPath {
+		base, link = "../", "feed/"+url.PathEscape(name)
+	}
+
+	var entries []Entry
+	for _, e := range <-fc {
+		if (byPath && (feedID(e.Source) == name || e.FeedName == name)) || (!byPath && e.Source == source) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return b.When.Compare(a.When)
+	})
+	for i := range entries {
+		entries[i].When = entries[i].When.In(zone)
+	}
+	p, start, end := paging(len(entries), pageOf(r))
+	feedPage.Execute(w, struct {
+		Name    string
+		Base    string
+		Link    string
+		Entries []Entry
+		Paging
+	}{entries[0].FeedName, base, link, entries[start:end], p})
+}
+
+var monthPage = newPage("month", monthPageTemplate)
+
+var monthPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS {{.Start.Format "January 2006"}}</title>
+</head>
+
+<body>
+		<nav class="details"><a href="month?m={{.Prev}}">←</a> {{.Start.Format "January 2006"}}{{with .Next}} <a href="month?m={{.}}">→</a>{{end}}</nav>
+		<div class="card">
+			<h1>{{.Start.Format "January 2006"}}</h1>
+			<table class="calendar">
+				<tr class="details"><th>Mon</th><th>Tue</th><th>Wed</th><th>Thu</th><th>Fri</th><th>Sat</th><th>Sun</th></tr>
+{{range .Weeks}}
+				<tr>{{range .}}<td>{{if .Day.IsZero}}{{else if .Future}}<span class="details">{{.Day.Day}}</span>{{else}}<a href="day/{{.Day.Format "2006-01-02"}}">{{.Day.Day}}</a><br><span class="details">{{if .Entries}}{{.Entries}}{{else}}–{{end}}</span>{{end}}</td>{{end}}</tr>
+{{end}}
+			</table>
+		</div>
+</body>
+</html>
+`
+
+var feedPage = newPage("feed", feedPageTemplate)
+
+var feedPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+{{with .Base}}	<base href="{{.}}">
+{{end}}	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS {{.Name}}</title>
+</head>
+
+<body>
+		<div class="card">
+			<h1>{{.Name}}</h1>
+			<ul>
+{{range .Entries}}
+				<li class="card-item{{if .Read}} read{{end}}">{{template "media" .}}<span class="details">{{with .Author}} by {{.}}{{end}} <a href="day?date={{day .When}}">{{.When.Format "2 Jan 2006"}}</a></span>{{template "star" .}}{{template "summary" .}}</li>
+{{end}}
+			</ul>
+		</div>
+{{template "pages" .}}</body>
+</html>
+`
@@ -0,0 +1,47 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+var styleDir = flag.String("style", "style", "Directory of files, like feed.css, to serve instead of the built-in ones of the same name")
+
+// builtinStyle is the style/ directory, so it's there wherever webrss is run.
+//
+//go:embed style
+var builtinStyle embed.FS
+
+// styleFiles is the files in the -style, or the built-in ones
+// when they're not there.
+func styleFiles() fs.FS {
+	builtin, err := fs.Sub(builtinStyle, "style")
+	if err != nil {
+		panic(err)
+	}
+	return overlayFS{os.DirFS(*styleDir), builtin}
+}
+
+// styleHandler serves the styleFiles.
+func styleHandler() http.Handler {
+	return http.FileServerFS(styleFiles())
+}
+
+// overlayFS opens files in top, or in bottom, if top doesn't have them.
+type overlayFS struct {
+	top, bottom fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.bottom.Open(name)
+	}
+	return f, err
+}
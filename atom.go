@@ -0,0 +1,115 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+var atomEntries = flag.Int("atom-entries", 100, "Most entries in feed.atom, newest first")
+
+// atomFeed is an Atom feed of the entries collected from every feed,
+// for other readers.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Author     string         `xml:"author>name"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    string         `xml:"content,omitempty"`
+	// Source is the feed the entry came from.
+	Source struct {
+		Title string     `xml:"title"`
+		ID    string     `xml:"id"`
+		Links []atomLink `xml:"link"`
+	} `xml:"source"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	URL  string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// writeAtom writes the first -atom-entries of entries, which are newest
+// first, as an Atom feed, which is at self.
+func writeAtom(w io.Writer, self string, entries []Entry) error {
+	if *atomEntries > 0 && len(entries) > *atomEntries {
+		entries = entries[:*atomEntries]
+	}
+
+	feed := atomFeed{
+		Title: "WEBRSS",
+		ID:    self,
+		Links: []atomLink{{Rel: "self", Type: "application/atom+xml", URL: self}},
+	}
+	updated := time.Unix(0, 0)
+	if len(entries) > 0 {
+		updated = entries[0].When
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	for _, e := range entries {
+		a := atomEntry{
+			Title:   e.Title,
+			ID:      "tag:webrss,2021:" + e.ID(),
+			Updated: e.When.UTC().Format(time.RFC3339),
+			Author:  firstOf(e.Author, e.FeedName),
+			Summary: e.Summary,
+			Content: e.Content,
+		}
+		if e.URL != "" {
+			a.Links = append(a.Links, atomLink{Rel: "alternate", URL: e.URL})
+		}
+		for _, enc := range e.Enclosures {
+			a.Links = append(a.Links, atomLink{Rel: "enclosure", Type: enc.Type, URL: enc.URL})
+		}
+		for _, c := range e.Categories {
+			a.Categories = append(a.Categories, atomCategory{c})
+		}
+		a.Source.Title = e.FeedName
+		a.Source.ID = e.Source
+		a.Source.Links = []atomLink{{Rel: "alternate", URL: e.FeedURL}, {Rel: "self", URL: e.Source}}
+		feed.Entries = append(feed.Entries, a)
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	return enc.Encode(feed)
+}
+
+// showAtom serves the entries collected from every feed, with the same
+// stories from different feeds collapsed together, as an Atom feed.
+// Muted feeds are left out, like they are from the daily page.
+func showAtom(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	entries := slices.Clone(unmuted(<-fc))
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return b.When.Compare(a.When)
+	})
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	writeAtom(w, scheme+"://"+r.Host+r.URL.Path, collapse(entries))
+}
@@ -0,0 +1,177 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var restore = flag.String("restore", "", "Before starting, put back the store, feeds file, and feed statuses from this backup, made by POSTing to /admin/backup")
+
+// The files in a backup besides the store, which are kept next to the -cache.
+var backupFiles = []string{"status.gob", "validators.gob", "cookies.gob", "mutes.gob"}
+
+// storeFile is where the store's entries are kept.
+// With s3, that's the -cache file, which is replaced with the copy in the bucket.
+func storeFile() string {
+	kind, path, _ := strings.Cut(*store, ":")
+	if *store == "" || kind == "s3" {
+		return *cache
+	}
+	return path
+}
+
+// backup writes a gzipped tar of the feeds file, the files in backupFiles
+// that there are, and what's in st. The store comes last, so it's newer
+// than the feeds file when it's restored, and isn't thrown out for being stale.
+func backup(w io.Writer, st Store) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, b []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(b)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		return err
+	}
+
+	if *feeds != "" {
+		b, err := os.ReadFile(*feeds)
+		if err != nil {
+			return err
+		}
+		if err := add("feeds", b); err != nil {
+			return err
+		}
+	}
+	for _, name := range backupFiles {
+		b, err := os.ReadFile(filepath.Join(filepath.Dir(*cache), name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := add(name, b); err != nil {
+			return err
+		}
+	}
+	var stored bytes.Buffer
+	if err := st.Backup(&stored); err != nil {
+		return err
+	}
+	if err := add("store", stored.Bytes()); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// restoreBackup puts back the files in the backup at name,
+// replacing those there are.
+func restoreBackup(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		var path string
+		// The feeds file and cookies can have credentials in them,
+		// so they're only for whoever runs webrss to read.
+		mode := fs.FileMode(0644)
+		switch {
+		case h.Name == "store":
+			path = storeFile()
+			// A write-ahead log left from the old database would be
+			// applied to the restored one.
+			os.Remove(path + "-wal")
+			os.Remove(path + "-shm")
+		case h.Name == "feeds":
+			if *feeds == "" {
+				log.Printf("Not restoring the feeds file, since there's no -feeds.\n")
+				continue
+			}
+			path = *feeds
+			mode = 0600
+		default:
+			for _, b := range backupFiles {
+				if h.Name == b {
+					path = filepath.Join(filepath.Dir(*cache), b)
+				}
+			}
+			if h.Name == "cookies.gob" {
+				mode = 0600
+			}
+		}
+		if path == "" {
+			log.Printf("Not restoring %s, which I don't know.\n", h.Name)
+			continue
+		}
+		err = swapFile(path, func(f *os.File) error {
+			if _, err := io.Copy(f, tr); err != nil {
+				return err
+			}
+			return f.Chmod(mode)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("Restored %s.\n", name)
+	return nil
+}
+
+// backupHandler serves a backup of st to download.
+func backupHandler(st Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Backing up takes a POST.", http.StatusMethodNotAllowed)
+			return
+		}
+		var b bytes.Buffer
+		if err := backup(&b, st); err != nil {
+			log.Printf("Couldn't back up: %v\n", err)
+			http.Error(w, "The backup failed.", http.StatusInternalServerError)
+			return
+		}
+		name := "webrss-" + time.Now().In(zone).Format(dayFormat) + ".tar.gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.Write(b.Bytes())
+	})
+}
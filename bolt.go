@@ -0,0 +1,203 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltStore keeps the entries in a bbolt database, with -store=bolt:path.
+// Each feed has a bucket, named for the URL it's listed as, of its entries
+// in the order they were posted.
+// The database is only swapped out from under it by Compact.
+type boltStore struct {
+	mu   sync.RWMutex
+	path string
+	db   *bolt.DB
+}
+
+// openBolt opens the database at path, creating it if needed.
+func openBolt(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0644, boltOptions)
+	return &boltStore{path: path, db: db}, err
+}
+
+var boltOptions = &bolt.Options{Timeout: 5 * time.Second}
+
+// boltKey orders e within its feed's bucket by when it was posted.
+func boltKey(e Entry) []byte {
+	return append(boltTime(e.When), e.key()...)
+}
+
+// boltTime is the start of the keys of entries posted at t.
+func boltTime(t time.Time) []byte {
+	k := make([]byte, 8)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	}
+	return k
+}
+
+// SaveEntries only writes the entries that have changed, and drops
+// those that are gone, rather than rewriting every bucket, so most saves
+// write little. Entries without a Source, which no feed gave, have no
+// bucket to go in, so they're left out.
+func (s *boltStore) SaveEntries(entries []Entry) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bySource := map[string][]Entry{}
+	for _, e := range entries {
+		if e.Source != "" {
+			bySource[e.Source] = append(bySource[e.Source], e)
+		}
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var gone [][]byte
+		err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if _, ok := bySource[string(name)]; !ok {
+				gone = append(gone, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range gone {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		for source, entries := range bySource {
+			b, err := tx.CreateBucketIfNotExists([]byte(source))
+			if err != nil {
+				return err
+			}
+			want := map[string][]byte{}
+			for _, e := range entries {
+				var v bytes.Buffer
+				if err := gob.NewEncoder(&v).Encode(e); err != nil {
+					return err
+				}
+				want[string(boltKey(e))] = v.Bytes()
+			}
+			var stale [][]byte
+			err = b.ForEach(func(k, _ []byte) error {
+				if _, ok := want[string(k)]; !ok {
+					stale = append(stale, bytes.Clone(k))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range stale {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			for k, v := range want {
+				if bytes.Equal(b.Get([]byte(k)), v) {
+					continue
+				}
+				if err := b.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Entries() ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(_, v []byte) error {
+				e, err := decodeBolt(v)
+				entries = append(entries, e)
+				return err
+			})
+		})
+	})
+	return entries, err
+}
+
+func (s *boltStore) Search(q string, limit int) ([]Entry, error) {
+	entries, err := s.Entries()
+	return searchEntries(entries, q, limit), err
+}
+
+// Prune has nothing to do, since only the current entries are kept.
+func (s *boltStore) Prune(since time.Time, perFeed int) (int, error) {
+	return 0, nil
+}
+
+// Compact copies the database to a new file, leaving out the pages freed
+// by replacing the entries, which bbolt never gives back, and swaps it in.
+func (s *boltStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := s.path + ".compact"
+	dst, err := bolt.Open(tmp, 0644, boltOptions)
+	if err != nil {
+		return err
+	}
+	err = bolt.Compact(dst, s.db, 1<<20)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	err = os.Rename(tmp, s.path)
+	if err != nil {
+		os.Remove(tmp)
+	}
+	// Whether or not it was replaced, it has to be reopened.
+	db, oerr := bolt.Open(s.path, 0644, boltOptions)
+	if oerr != nil {
+		return oerr
+	}
+	s.db = db
+	return err
+}
+
+// Backup writes a copy of the database as of one read transaction.
+func (s *boltStore) Backup(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+func (s *boltStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
+
+func decodeBolt(v []byte) (Entry, error) {
+	var e Entry
+	err := gob.NewDecoder(bytes.NewReader(v)).Decode(&e)
+	return e, err
+}
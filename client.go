@@ -0,0 +1,149 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+var connectTimeout = flag.Duration("connect-timeout", 10*time.Second, "Longest to wait to connect to a site")
+var readTimeout = flag.Duration("read-timeout", 30*time.Second, "Longest to wait for a site to respond once connected")
+var cacert = flag.String("cacert", "", "File of more certificate authorities to trust, like a TLS-intercepting proxy's")
+var proxy = flag.String("proxy", "", "Proxy for requests to other sites, like http://host:port or socks5://host:port (default from HTTP_PROXY and friends)")
+var defaultUserAgent = flag.String("user-agent", "", "User-Agent to send to sites, for feeds without their own")
+var timeout = flag.Duration("timeout", time.Minute, "Longest a whole request to a site can take, including reading it")
+
+// tlsOptions are how a feed that needs it verifies its site's certificate.
+type tlsOptions struct {
+	// caFile has the certificates of more authorities to trust.
+	caFile string
+	// insecure skips verifying the certificate at all.
+	insecure bool
+}
+
+// transports holds the transport for each set of TLS options, so
+// feeds with the same ones share connections.
+var transports = struct {
+	sync.Mutex
+	byTLS map[tlsOptions]http.RoundTripper
+}{
+	byTLS: map[tlsOptions]http.RoundTripper{},
+}
+
+// newClient returns a client for talking to other sites, which gives up
+// on them according to the timeout flags and doesn't send any one of them
+// requests too quickly. Its connections are shared.
+func newClient() *http.Client {
+	c, _ := newTLSClient(tlsOptions{})
+	return c
+}
+
+// newTLSClient is like newClient, but verifies certificates according
+// to opts, without affecting any other client.
+func newTLSClient(opts tlsOptions) (*http.Client, error) {
+	transports.Lock()
+	defer transports.Unlock()
+	t, ok := transports.byTLS[opts]
+	if !ok {
+		config, err := opts.config()
+		if err != nil {
+			return nil, err
+		}
+		proxyFunc, _ := proxyFunc()
+		dialer := &net.Dialer{
+			Timeout:   *connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		t = agentSetter{hostLimiter{&http.Transport{
+			Proxy:                 proxyFunc,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       config,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   *connectTimeout,
+			ResponseHeaderTimeout: *readTimeout,
+			ExpectContinueTimeout: time.Second,
+		}}}
+		transports.byTLS[opts] = t
+	}
+	return &http.Client{
+		Transport: t,
+		Jar:       cookies,
+		Timeout:   *timeout,
+	}, nil
+}
+
+// config makes the TLS configuration for opts, which trusts the
+// authorities in -cacert as well as the system's, or nil if they're
+// all defaults.
+func (opts tlsOptions) config() (*tls.Config, error) {
+	if opts == (tlsOptions{}) && *cacert == "" {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: opts.insecure}
+	var cas []string
+	for _, f := range []string{*cacert, opts.caFile} {
+		if f != "" {
+			cas = append(cas, f)
+		}
+	}
+	if len(cas) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, f := range cas {
+			pem, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in %s", f)
+			}
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// proxyFunc picks the proxy for each request: the one given with -proxy,
+// or otherwise the one the environment says to use, if any.
+func proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if *proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(*proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("can't use a %q proxy", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// agentSetter is a RoundTripper that sends -user-agent with requests
+// that don't give their own.
+type agentSetter struct {
+	next http.RoundTripper
+}
+
+func (a agentSetter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", accessFor("").userAgent)
+	}
+	return a.next.RoundTrip(req)
+}
@@ -0,0 +1,49 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+var compactEvery = flag.Duration("compact-every", 24*time.Hour, "How often to compact the store, giving back the space of dropped entries, or 0 to never")
+
+// compacting ticks when it's time to compact, or never with -compact-every=0.
+func compacting() <-chan time.Time {
+	if *compactEvery <= 0 {
+		return nil
+	}
+	return time.Tick(*compactEvery)
+}
+
+// compact compacts st, logging how it went.
+func compact(st Store) {
+	start := time.Now()
+	if err := st.Compact(); err != nil {
+		log.Printf("Couldn't compact the store: %v\n", err)
+		return
+	}
+	log.Printf("Compacted the store in %v.\n", time.Since(start).Round(time.Millisecond))
+}
+
+// compactHandler asks for the store to be compacted now.
+func compactHandler(toCompact chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Compacting takes a POST.", http.StatusMethodNotAllowed)
+			return
+		}
+		select {
+		case toCompact <- struct{}{}:
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "Compacting.\n")
+	})
+}
@@ -0,0 +1,69 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var maxSize = flag.Int64("max-size", 20<<20, "Most bytes of a feed to read, after decompressing")
+
+// decompress wraps body according to its Content-Encoding. Some servers
+// compress without saying so, or say so without compressing, so decompress
+// trusts the first few bytes over the header.
+func decompress(body io.Reader, contentEncoding string) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	magic, _ := br.Peek(2)
+	isGzip := len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+	isZlib := len(magic) == 2 && magic[0]&0x0f == 8 && (uint16(magic[0])<<8|uint16(magic[1]))%31 == 0
+
+	switch {
+	case isGzip:
+		return gzip.NewReader(br)
+	case isZlib:
+		return zlib.NewReader(br)
+	case strings.EqualFold(strings.TrimSpace(contentEncoding), "deflate") && !looksLikeText(magic):
+		return flate.NewReader(br), nil
+	}
+	return br, nil
+}
+
+// looksLikeText reports whether b could be the start of an uncompressed feed.
+func looksLikeText(b []byte) bool {
+	for _, c := range b {
+		if c < 0x09 || (c > 0x0d && c < 0x20) {
+			return false
+		}
+	}
+	return true
+}
+
+// sizeLimit is a reader that fails once more than n bytes are read from r,
+// rather than quietly stopping like io.LimitReader.
+type sizeLimit struct {
+	r io.Reader
+	n int64
+}
+
+func (l *sizeLimit) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("it's bigger than the -max-size of %d bytes", *maxSize)
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		n += int(l.n)
+		return n, fmt.Errorf("it's bigger than the -max-size of %d bytes", *maxSize)
+	}
+	return n, err
+}
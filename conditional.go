@@ -0,0 +1,142 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNotModified is returned by loadFeed when the server says the feed
+// hasn't changed since it was last fetched.
+var errNotModified = errors.New("not modified")
+
+// unchanged is sent by getFeed for a feed whose cached entries are still good.
+type unchanged string
+
+func (u unchanged) Error() string {
+	return string(u) + ": unchanged"
+}
+
+// validator is what a server said identifies the version of a feed it sent.
+type validator struct {
+	ETag         string
+	LastModified string
+}
+
+// validators holds the validator of each feed, by the URL it was fetched from.
+var validators = struct {
+	sync.Mutex
+	v map[string]validator
+}{
+	v: map[string]validator{},
+}
+
+// validatorsFile is where validators are kept, next to the feed cache.
+func validatorsFile() string {
+	return filepath.Join(filepath.Dir(*cache), "validators.gob")
+}
+
+// loadValidators reads the validators saved by the last run.
+// They're only any use if the entries they validate were cached, too.
+func loadValidators() {
+	b, err := readFile(validatorsFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		log.Printf("Couldn't read %s: %v\n", validatorsFile(), err)
+		return
+	}
+	validators.Lock()
+	defer validators.Unlock()
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&validators.v)
+	if err != nil {
+		log.Printf("Couldn't read %s: %v\n", validatorsFile(), err)
+	}
+}
+
+func saveValidators() {
+	validators.Lock()
+	defer validators.Unlock()
+	err := replaceFile(validatorsFile(), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(validators.v)
+	})
+	if err != nil {
+		log.Printf("Couldn't save validators: %v\n", err)
+	}
+}
+
+// askIfModified makes req conditional on the feed at u having changed.
+func askIfModified(req *http.Request, u string) {
+	validators.Lock()
+	v := validators.v[u]
+	validators.Unlock()
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// remember keeps the validators of the feed at u from resp.
+func remember(u string, resp *http.Response) {
+	v := validator{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	validators.Lock()
+	defer validators.Unlock()
+	if v == (validator{}) {
+		delete(validators.v, u)
+		return
+	}
+	validators.v[u] = v
+}
+
+// freshFor is how long resp says it can be cached before it's worth
+// asking for again, or 0 if it doesn't say.
+func freshFor(resp *http.Response) time.Duration {
+	var maxAge time.Duration
+	found := false
+	for _, cc := range resp.Header.Values("Cache-Control") {
+		for _, d := range strings.Split(cc, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			switch strings.ToLower(name) {
+			case "no-cache", "no-store":
+				return 0
+			case "max-age":
+				secs, err := strconv.Atoi(strings.Trim(value, `"`))
+				if err == nil {
+					maxAge = time.Duration(secs) * time.Second
+					found = true
+				}
+			}
+		}
+	}
+	if !found {
+		expires, err := http.ParseTime(resp.Header.Get("Expires"))
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		maxAge = expires.Sub(date)
+	}
+	if age, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		maxAge -= time.Duration(age) * time.Second
+	}
+	return max(maxAge, 0)
+}
@@ -0,0 +1,112 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookies is the jar of every client from newClient. It keeps what sites set,
+// so feeds behind a session keep working across restarts.
+var cookies = newJar()
+
+// jar is a cookie jar that remembers the cookies set on it, so they
+// can be saved and set again later.
+type jar struct {
+	*cookiejar.Jar
+
+	mu   sync.Mutex
+	kept map[string][]*http.Cookie // By the site they were set from.
+}
+
+func newJar() *jar {
+	j, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &jar{Jar: j, kept: map[string][]*http.Cookie{}}
+}
+
+func (j *jar) SetCookies(u *url.URL, cs []*http.Cookie) {
+	j.Jar.SetCookies(u, cs)
+
+	site := u.Scheme + "://" + u.Host + "/"
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cs {
+		c := *c
+		// Max-Age is from now, which won't be when they're set again.
+		if c.MaxAge > 0 {
+			c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		kept := j.kept[site][:0]
+		for _, k := range j.kept[site] {
+			if k.Name != c.Name || k.Path != c.Path || k.Domain != c.Domain {
+				kept = append(kept, k)
+			}
+		}
+		if c.MaxAge < 0 {
+			j.kept[site] = kept
+			continue
+		}
+		j.kept[site] = append(kept, &c)
+	}
+}
+
+// cookieFile is where cookies are kept, next to the feed cache.
+func cookieFile() string {
+	return filepath.Join(filepath.Dir(*cache), "cookies.gob")
+}
+
+// loadCookies sets the cookies saved by the last run.
+func loadCookies() {
+	b, err := readFile(cookieFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		log.Printf("Couldn't read %s: %v\n", cookieFile(), err)
+		return
+	}
+	var kept map[string][]*http.Cookie
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&kept)
+	if err != nil {
+		log.Printf("Couldn't read %s: %v\n", cookieFile(), err)
+		return
+	}
+	now := time.Now()
+	for site, cs := range kept {
+		u, err := url.Parse(site)
+		if err != nil {
+			continue
+		}
+		var live []*http.Cookie
+		for _, c := range cs {
+			if c.Expires.IsZero() || c.Expires.After(now) {
+				live = append(live, c)
+			}
+		}
+		cookies.SetCookies(u, live)
+	}
+}
+
+func saveCookies() {
+	cookies.mu.Lock()
+	defer cookies.mu.Unlock()
+	err := replaceFile(cookieFile(), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(cookies.kept)
+	})
+	if err != nil {
+		log.Printf("Couldn't save cookies: %v\n", err)
+	}
+}
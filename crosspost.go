@@ -0,0 +1,56 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minTitleMatch is the shortest normalized title that's distinctive enough
+// to call two entries the same story.
+const minTitleMatch = 20
+
+// collapse merges entries that are the same story from different feeds,
+// as happens with planets and other aggregators. Two entries are the same
+// if their links are, or if their titles are all but identical.
+// The first of each story is kept, with the rest in its Also.
+func collapse(entries []Entry) []Entry {
+	var kept []Entry
+	byURL := map[string]int{}
+	byTitle := map[string]int{}
+	for _, e := range entries {
+		u := canonicalURL(e.URL)
+		t := normalizeTitle(e.Title)
+
+		i, ok := byURL[u]
+		if !ok && len(t) >= minTitleMatch {
+			i, ok = byTitle[t]
+		}
+		if ok && kept[i].FeedName != e.FeedName {
+			kept[i].Also = append(kept[i].Also, e)
+			continue
+		}
+
+		kept = append(kept, e)
+		if u != "" {
+			byURL[u] = len(kept) - 1
+		}
+		if len(t) >= minTitleMatch {
+			byTitle[t] = len(kept) - 1
+		}
+	}
+	return kept
+}
+
+// normalizeTitle keeps only the lowercase letters and digits of a title,
+// single-spaced.
+func normalizeTitle(t string) string {
+	t = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, t)
+	return strings.Join(strings.Fields(t), " ")
+}
@@ -0,0 +1,108 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// rssTimeFormats are the layouts tried, in order, for RSS dates after
+// they've been through normalizeRssTime. RFC 822 is the standard, but
+// feeds drop the weekday or seconds, spell out names, use two-digit years,
+// write the date with dashes like RFC 850, or give ISO 8601 instead.
+var rssTimeFormats = func() []string {
+	var fmts []string
+	for _, wday := range []string{"Mon ", "Monday ", ""} {
+		for _, month := range []string{"Jan", "January"} {
+			for _, year := range []string{"2006", "06"} {
+				for _, sep := range []string{" ", "-"} {
+					date := wday + "2" + sep + month + sep + year
+					for _, clock := range []string{" 15:04:05", " 15:04"} {
+						for _, zone := range []string{" -0700", " -07:00", ""} {
+							fmts = append(fmts, date+clock+zone)
+						}
+					}
+					fmts = append(fmts, date)
+				}
+			}
+		}
+	}
+	return append(fmts,
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05 -07:00",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		time.DateOnly,
+		time.ANSIC,
+		time.UnixDate,
+		time.RubyDate,
+	)
+}()
+
+// zoneOffsets are the named zones seen in the wild, which time.Parse
+// would otherwise take as UTC unless they happen to be local.
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000", "WET": "+0000",
+	"EST": "-0500", "EDT": "-0400",
+	"CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600",
+	"PST": "-0800", "PDT": "-0700",
+	"AKST": "-0900", "AKDT": "-0800",
+	"HST": "-1000",
+	"BST": "+0100", "IST": "+0530",
+	"CET": "+0100", "CEST": "+0200", "MET": "+0100", "MEST": "+0200",
+	"EET": "+0200", "EEST": "+0300", "WEST": "+0100",
+	"MSK": "+0300",
+	"JST": "+0900", "KST": "+0900",
+	"AEST": "+1000", "AEDT": "+1100", "ACST": "+0930", "AWST": "+0800",
+	"NZST": "+1200", "NZDT": "+1300",
+}
+
+var (
+	parenthetical = regexp.MustCompile(`\s*\([^)]*\)\s*$`)
+	trailingZone  = regexp.MustCompile(`\s([A-Za-z]{1,5})$`)
+	gmtOffset     = regexp.MustCompile(`\s(?:GMT|UTC)([+-]\d{2}):?(\d{2})$`)
+)
+
+// normalizeRssTime tidies ts so it can match rssTimeFormats: no commas,
+// single spaces, three-letter weekdays, and numeric zones.
+func normalizeRssTime(ts string) string {
+	ts = strings.ReplaceAll(ts, ",", " ")
+	ts = parenthetical.ReplaceAllString(ts, "")
+	ts = strings.Join(strings.Fields(ts), " ")
+	ts = gmtOffset.ReplaceAllString(ts, " $1$2")
+
+	if m := trailingZone.FindStringSubmatch(ts); m != nil {
+		if off, ok := zoneOffsets[strings.ToUpper(m[1])]; ok {
+			ts = ts[:len(ts)-len(m[1])] + off
+		}
+	}
+
+	// Feeds write "Tues" and "Thur", which time.Parse won't take.
+	if wday, rest, ok := strings.Cut(ts, " "); ok {
+		switch strings.ToLower(wday) {
+		case "tues", "thur", "thurs", "weds":
+			ts = wday[:3] + " " + rest
+		}
+	}
+	return ts
+}
+
+func parseRssTimes(ts string) (time.Time, error) {
+	norm := normalizeRssTime(ts)
+	for _, f := range rssTimeFormats {
+		t, err := time.Parse(f, norm)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", ts)
+}
@@ -0,0 +1,59 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRssTimes(t *testing.T) {
+	est := time.FixedZone("", -5*60*60)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 EST", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Monday, 02-Jan-06 15:04:05 EST", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Monday, 02-Jan-06 20:04:05 GMT", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"Mon, 02 Jan 06 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"02 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Mon, 02 Jan 2006 15:04 -0500", time.Date(2006, 1, 2, 15, 4, 0, 0, est)},
+		{"Mon, 02 Jan 2006 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Monday, 2 January 2006 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Tues, 03 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 3, 15, 4, 5, 0, est)},
+		{"Thur, 05 Jan 2006 15:04:05 -0500", time.Date(2006, 1, 5, 15, 4, 5, 0, est)},
+		{"Mon,  02 Jan 2006   15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Mon, 02 Jan 2006 15:04:05 -05:00", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Mon, 02 Jan 2006 15:04:05 GMT-05:00", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"Mon, 02 Jan 2006 20:04:05 +0000 (UTC)", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"Mon, 02 Jan 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2006-01-02T15:04:05-05:00", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"2006-01-02T20:04:05.123Z", time.Date(2006, 1, 2, 20, 4, 5, 123e6, time.UTC)},
+		{"2006-01-02T15:04:05-0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"2006-01-02T20:04:05", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"2006-01-02 15:04:05 -0500", time.Date(2006, 1, 2, 15, 4, 5, 0, est)},
+		{"2006-01-02", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"Mon Jan 2 20:04:05 2006", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseRssTimes(tt.in)
+		if err != nil {
+			t.Errorf("parseRssTimes(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseRssTimes(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRssTimesRejects(t *testing.T) {
+	for _, in := range []string{"", "yesterday", "32 Jan 2006", "Mon, 02 Foo 2006 15:04:05 GMT"} {
+		if got, err := parseRssTimes(in); err == nil {
+			t.Errorf("parseRssTimes(%q) = %v, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var errNoFeed = errors.New("page doesn't link to a feed")
+
+var feedTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/feed+json",
+	"application/json",
+	"application/rdf+xml",
+}
+
+// isHTML reports whether a Content-Type header describes a web page.
+func isHTML(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
+
+// discoverFeed scans an HTML page for a <link rel="alternate"> to a feed
+// and returns the feed's URL, resolved against base.
+func discoverFeed(base *url.URL, r io.Reader) (string, error) {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return "", errNoFeed
+			}
+			return "", z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "body" {
+				return "", errNoFeed
+			}
+			if t.Data != "link" {
+				continue
+			}
+			if !hasRel(attr(t, "rel"), "alternate") {
+				continue
+			}
+			mt, _, _ := mime.ParseMediaType(attr(t, "type"))
+			href := attr(t, "href")
+			if href == "" || !slices.Contains(feedTypes, mt) {
+				continue
+			}
+			u, err := base.Parse(href)
+			if err != nil {
+				return "", err
+			}
+			return u.String(), nil
+		}
+	}
+}
+
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// hasRel reports whether the space-separated rel attribute contains want.
+func hasRel(rel, want string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, want) {
+			return true
+		}
+	}
+	return false
+}
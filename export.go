@@ -0,0 +1,147 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var exportFormat = flag.String("export", "", "Write the stored entries to standard output as json or csv, and exit")
+var exportSince = flag.String("export-since", "", "With -export, only entries from this day on, like 2021-06-01")
+var exportUntil = flag.String("export-until", "", "With -export, only entries before this day, like 2021-07-01")
+var exportFeed = flag.String("export-feed", "", "With -export, only entries of the feed listed as this URL")
+
+// exportFilter picks which entries to export.
+type exportFilter struct {
+	since, until time.Time
+	source       string
+}
+
+// newExportFilter parses since and until, which are days or empty,
+// for a filter of the entries between them from the feed listed as source,
+// or any feed if it's empty.
+func newExportFilter(since, until, source string) (exportFilter, error) {
+	f := exportFilter{source: source}
+	var err error
+	if since != "" {
+		f.since, err = parseDay(since)
+		if err != nil {
+			return f, fmt.Errorf("the start must be a day like 2021-06-01: %w", err)
+		}
+	}
+	if until != "" {
+		f.until, err = parseDay(until)
+		if err != nil {
+			return f, fmt.Errorf("the end must be a day like 2021-07-01: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// apply is the entries that pass the filter, oldest first.
+func (f exportFilter) apply(entries []Entry) []Entry {
+	var picked []Entry
+	for _, e := range entries {
+		if (f.source == "" || e.Source == f.source) &&
+			!e.When.Before(f.since) &&
+			(f.until.IsZero() || e.When.Before(f.until)) {
+			picked = append(picked, e)
+		}
+	}
+	slices.SortFunc(picked, func(a, b Entry) int {
+		return a.When.Compare(b.When)
+	})
+	return picked
+}
+
+// exportEntries writes entries to w as json or csv.
+func exportEntries(w io.Writer, format string, entries []Entry) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(entries)
+	case "csv":
+		return writeCSV(w, entries)
+	}
+	return fmt.Errorf("I can only export json or csv, not %q", format)
+}
+
+// csvHeader names the columns writeCSV writes.
+var csvHeader = []string{
+	"when", "feed", "feed_url", "source", "title", "url", "author", "summary",
+	"categories", "enclosures", "comments", "guid", "read", "starred",
+}
+
+func writeCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, e := range entries {
+		var enclosures []string
+		for _, enc := range e.Enclosures {
+			enclosures = append(enclosures, enc.URL)
+		}
+		cw.Write([]string{
+			e.When.UTC().Format(time.RFC3339), e.FeedName, e.FeedURL, e.Source,
+			e.Title, e.URL, e.Author, e.Summary,
+			strings.Join(e.Categories, "; "), strings.Join(enclosures, " "),
+			e.Comments, e.GUID, strconv.FormatBool(e.Read), strconv.FormatBool(e.Starred),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportStore writes the entries in the store, picked by the -export flags,
+// to standard output.
+func exportStore() error {
+	f, err := newExportFilter(*exportSince, *exportUntil, *exportFeed)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	entries, err := st.Entries()
+	if err != nil {
+		return err
+	}
+	return exportEntries(os.Stdout, *exportFormat, f.apply(entries))
+}
+
+// exportHandler serves the entries as a download, in the format given
+// as format, json by default. Like -export, they can be picked by the days
+// since and until, and the feed listed as feed.
+func exportHandler(fc <-chan []Entry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := newExportFilter(r.FormValue("since"), r.FormValue("until"), r.FormValue("feed"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := firstOf(r.FormValue("format"), "json")
+		switch format {
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		default:
+			http.Error(w, "The format must be json or csv.", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="webrss.`+format+`"`)
+		exportEntries(w, format, f.apply(<-fc))
+	})
+}
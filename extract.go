@@ -0,0 +1,233 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+var extract = flag.Bool("extract", false, "Fetch the article of each new entry and keep its text, to read and search here")
+
+// extractRecent is how new an entry must be to have its article extracted.
+// Older ones won't be shown anymore.
+const extractRecent = 48 * time.Hour
+
+// minParagraph is the shortest text that counts towards a paragraph
+// being part of an article, rather than a caption or a button.
+const minParagraph = 40
+
+// extractions tracks the entries whose articles have been extracted,
+// or tried to be, by key.
+var extractions = struct {
+	sync.Mutex
+	tried   map[string]bool
+	running bool
+}{
+	tried: map[string]bool{},
+}
+
+// extractArticles gets the text of the article of each recent entry that
+// doesn't have it yet, sending it to marks. Only one runs at a time.
+func extractArticles(ctx context.Context, entries []Entry, marks chan<- mark) {
+	if !*extract {
+		return
+	}
+	extractions.Lock()
+	if extractions.running {
+		extractions.Unlock()
+		return
+	}
+	extractions.running = true
+	extractions.Unlock()
+	defer func() {
+		extractions.Lock()
+		extractions.running = false
+		extractions.Unlock()
+	}()
+
+	texts := map[string]string{}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if e.Content != "" || e.URL == "" || time.Since(e.When) > extractRecent {
+			continue
+		}
+		extractions.Lock()
+		tried := extractions.tried[e.key()]
+		extractions.tried[e.key()] = true
+		extractions.Unlock()
+		if tried {
+			continue
+		}
+
+		text, err := fetchArticle(ctx, e.URL)
+		if err != nil {
+			log.Printf("Couldn't extract the article at %s: %v\n", e.URL, err)
+			continue
+		}
+		texts[e.ID()] = text
+	}
+	if len(texts) == 0 {
+		return
+	}
+
+	var ids []string
+	for id := range texts {
+		ids = append(ids, id)
+	}
+	select {
+	case marks <- mark{ids, func(e *Entry) { e.Content = texts[e.ID()] }}:
+		log.Printf("Extracted %d articles.\n", len(ids))
+	case <-ctx.Done():
+	}
+}
+
+// fetchArticle gets the page at u and extracts its article's text.
+func fetchArticle(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := newClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("got %s", resp.Status)
+	}
+	if !isHTML(resp.Header.Get("Content-Type")) {
+		return "", errors.New("not a web page")
+	}
+
+	body, err := charset.NewReader(&sizeLimit{resp.Body, *maxSize}, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return "", err
+	}
+	text := articleText(doc)
+	if text == "" {
+		return "", errors.New("couldn't find the article")
+	}
+	return text, nil
+}
+
+// articleText is the text of the article in doc, as paragraphs separated
+// by blank lines. The article is its <article> or <main>, or failing those,
+// whichever element has the most text in paragraphs of its own.
+func articleText(doc *html.Node) string {
+	root := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Article })
+	if root == nil {
+		root = find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Main })
+	}
+	if root == nil {
+		scores := map[*html.Node]int{}
+		for _, p := range findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.P }) {
+			if n := len(textOf(p)); n >= minParagraph && p.Parent != nil {
+				scores[p.Parent] += n
+			}
+		}
+		best := 0
+		for n, score := range scores {
+			if score > best {
+				root, best = n, score
+			}
+		}
+	}
+	if root == nil {
+		return ""
+	}
+	return strings.Join(paragraphs(root), "\n\n")
+}
+
+// paragraphs is the text of each block under n, like paragraphs and
+// headings, leaving out the parts of a page around an article.
+func paragraphs(n *html.Node) []string {
+	var ps []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Nav, atom.Header, atom.Footer,
+			atom.Aside, atom.Form, atom.Button, atom.Figure, atom.Iframe, atom.Svg:
+			continue
+		case atom.P, atom.Li, atom.Blockquote, atom.Pre, atom.Dt, atom.Dd:
+			if t := textOf(c); t != "" {
+				ps = append(ps, t)
+			}
+			continue
+		}
+		if isHeading(c) {
+			if t := textOf(c); t != "" {
+				ps = append(ps, t)
+			}
+			continue
+		}
+		if c.Type == html.TextNode {
+			if t := strings.Join(strings.Fields(c.Data), " "); t != "" {
+				ps = append(ps, t)
+			}
+			continue
+		}
+		ps = append(ps, paragraphs(c)...)
+	}
+	return ps
+}
+
+// Paragraphs is the extracted article, for showing.
+func (e Entry) Paragraphs() []string {
+	return strings.Split(e.Content, "\n\n")
+}
+
+// showArticle shows the extracted article of the entry with the given ID.
+func showArticle(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	id := r.FormValue("id")
+	for _, e := range <-fc {
+		if e.ID() == id && e.Content != "" {
+			articlePage.Execute(w, e)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+var articlePage = newPage("article", articlePageTemplate)
+
+var articlePageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>{{.Title}}</title>
+</head>
+
+<body>
+		<div class="card">
+			<h1><a href="{{.URL}}" ping="read?entry={{.ID}}">{{.Title}}</a></h1>
+			<p class="details">{{with .Author}}by {{.}} {{end}}(<a href="{{.FeedURL}}">{{with icon .FeedURL}}<img class="icon" src="{{.}}" alt="">{{end}}{{.FeedName}}</a>) {{.When.Format "2 Jan 2006"}}{{template "star" .}}</p>
+{{range .Paragraphs}}
+			<p>{{.}}</p>
+{{end}}
+		</div>
+</body>
+</html>
+`
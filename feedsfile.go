@@ -0,0 +1,296 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var secrets = flag.String("secrets", "", "file of settings for feeds, like the feeds file, for those better kept out of it")
+
+// readFeeds returns the URLs of the feeds given as arguments and listed
+// in the feeds file, if there is one. The settings in the secrets file
+// are applied to them, too. If either file can't be read, the settings
+// are left as they were.
+func readFeeds() ([]string, error) {
+	// Start over, in case settings were removed since the files were last read.
+	s := newFeedSettings()
+	urls := append([]string(nil), flag.Args()...)
+	if *feeds != "" {
+		listed, err := readFeedsFile(*feeds, s)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, listed...)
+	}
+	if *secrets != "" {
+		_, err := readFeedsFile(*secrets, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.use()
+	subscriptions.Lock()
+	subscriptions.urls = urls
+	subscriptions.Unlock()
+	return urls, nil
+}
+
+// subscriptions holds the URLs of the feeds from when they were last read.
+var subscriptions = struct {
+	sync.Mutex
+	urls []string
+}{}
+
+// subscribed is the URLs of the feeds.
+func subscribed() []string {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	return slices.Clone(subscriptions.urls)
+}
+
+// readFeedsFile puts the settings of each line in the file at name in s,
+// and returns the URLs they're for. The file may be sealed.
+// It may be an OPML file instead, whose folders are the feeds' groups.
+func readFeedsFile(name string, s *feedSettings) ([]string, error) {
+	b, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if isOPML(b) {
+		opml, err := parseOPML(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		var urls []string
+		for _, f := range opml {
+			if f.Group != "" {
+				s.setGroup(f.URL, f.Group)
+			}
+			urls = append(urls, f.URL)
+		}
+		return urls, nil
+	}
+	var urls []string
+	in := bufio.NewScanner(bytes.NewReader(b))
+	for in.Scan() {
+		u, err := parseFeedLine(in.Text(), s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls, in.Err()
+}
+
+// saveFeedsFile replaces the feeds file with b, keeping its permissions,
+// and sealing it if it was sealed.
+func saveFeedsFile(b []byte) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(*feeds); err == nil {
+		mode = info.Mode()
+	}
+	old, err := os.ReadFile(*feeds)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if isSealed(old) {
+		if b, err = seal(b); err != nil {
+			return err
+		}
+	}
+	return writeFileAs(*feeds, b, mode)
+}
+
+// parseFeedLine reads a line of the feeds file, which is a feed's URL,
+// optionally followed by how often to poll it and name=value settings:
+//
+//	https://example.com/feed.xml 6h user-agent="Mozilla/5.0 (compatible)"
+//	https://example.com/private.xml auth=me:hunter2
+//	https://example.com/paid.xml token=abc123
+//	https://example.com/members.xml cookie="session=xyz; remember=1"
+//	https://api.example.com/feed header="Accept: application/atom+xml" header="X-Api-Key: abc"
+//	https://intranet.example/feed.xml ca=/etc/ssl/intranet.pem
+//	https://self-signed.example/feed.xml tls=insecure
+//	https://comic.example/rss group=Comics
+//	https://friend.example/atom.xml title="A Friend"
+//	https://quiet.example/feed card=own
+//
+// The settings are put in s, and the feed's URL is returned,
+// or the empty string if the line is blank.
+func parseFeedLine(line string, s *feedSettings) (string, error) {
+	fields := splitQuoted(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	u := fields[0]
+	for _, f := range fields[1:] {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok {
+			d, err := parseInterval(f)
+			if err != nil {
+				return "", fmt.Errorf("bad interval for %s: %v", u, err)
+			}
+			s.setInterval(u, d)
+			continue
+		}
+		switch name {
+		case "user-agent":
+			s.setAccess(u, func(a *access) { a.userAgent = value })
+		case "auth":
+			user, pass, _ := strings.Cut(value, ":")
+			s.setAccess(u, func(a *access) { a.username, a.password = user, pass })
+		case "token":
+			s.setAccess(u, func(a *access) { a.token = value })
+		case "cookie":
+			s.setAccess(u, func(a *access) { a.cookie = value })
+		case "header":
+			hname, hvalue, ok := strings.Cut(value, ":")
+			if !ok {
+				return "", fmt.Errorf("bad header %q for %s", value, u)
+			}
+			s.setAccess(u, func(a *access) {
+				if a.headers == nil {
+					a.headers = http.Header{}
+				}
+				a.headers.Add(strings.TrimSpace(hname), strings.TrimSpace(hvalue))
+			})
+		case "ca":
+			opts := tlsOptions{caFile: value}
+			if _, err := opts.config(); err != nil {
+				return "", fmt.Errorf("bad CA for %s: %v", u, err)
+			}
+			s.setAccess(u, func(a *access) { a.tls.caFile = value })
+		case "group":
+			s.setGroup(u, value)
+		case "title":
+			s.setTitle(u, value)
+		case "card":
+			if value != "own" && value != "singles" {
+				return "", fmt.Errorf("unknown card %q for %s, which must be own or singles", value, u)
+			}
+			s.setCard(u, value)
+		case "tls":
+			if value != "insecure" {
+				return "", fmt.Errorf("unknown TLS option %q for %s", value, u)
+			}
+			s.setAccess(u, func(a *access) { a.tls.insecure = true })
+		default:
+			return "", fmt.Errorf("unknown setting %q for %s", name, u)
+		}
+	}
+	return u, nil
+}
+
+// feedSettings are the settings of feeds from the feeds and secrets files,
+// by the URL each feed is listed as, until use puts them in place.
+type feedSettings struct {
+	accesses map[string]access
+	every    map[string]time.Duration
+	groups   map[string]string
+	// order is the groups in the order they're first given.
+	order  []string
+	titles map[string]string
+	cards  map[string]string
+}
+
+func newFeedSettings() *feedSettings {
+	return &feedSettings{
+		accesses: map[string]access{},
+		every:    map[string]time.Duration{},
+		groups:   map[string]string{},
+		titles:   map[string]string{},
+		cards:    map[string]string{},
+	}
+}
+
+// setAccess changes the access of the feed listed as u.
+func (s *feedSettings) setAccess(u string, change func(*access)) {
+	a := s.accesses[u]
+	change(&a)
+	s.accesses[u] = a
+}
+
+// setInterval polls the feed at u every d, instead of every -freq.
+func (s *feedSettings) setInterval(u string, d time.Duration) {
+	s.every[u] = d
+}
+
+// setGroup puts the feed listed as u in the named group.
+func (s *feedSettings) setGroup(u, name string) {
+	s.groups[u] = name
+	if name != "" && !slices.Contains(s.order, name) {
+		s.order = append(s.order, name)
+	}
+}
+
+// setTitle shows the feed listed as u with the given title.
+func (s *feedSettings) setTitle(u, title string) {
+	s.titles[u] = title
+}
+
+// setCard shows the entries of the feed listed as u in the given card,
+// own or singles.
+func (s *feedSettings) setCard(u, card string) {
+	s.cards[u] = card
+}
+
+// use replaces the settings feeds are fetched and shown with by s.
+func (s *feedSettings) use() {
+	accesses.Lock()
+	accesses.byFeed = s.accesses
+	accesses.Unlock()
+	schedule.Lock()
+	schedule.every = s.every
+	schedule.Unlock()
+	groups.Lock()
+	groups.byFeed, groups.order = s.groups, s.order
+	groups.Unlock()
+	titles.Lock()
+	titles.byFeed = s.titles
+	titles.Unlock()
+	cards.Lock()
+	cards.byFeed = s.cards
+	cards.Unlock()
+}
+
+// splitQuoted splits s around spaces, except those in double quotes,
+// which are removed.
+func splitQuoted(s string) []string {
+	var fields []string
+	var field strings.Builder
+	inField, quoted := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
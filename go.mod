@@ -1,3 +1,12 @@
 module mccoy.space/g/webrss
 
-go 1.21
+go 1.24.0
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/net v0.21.0
+	golang.org/x/text v0.14.0
+)
+
+require golang.org/x/sys v0.18.0 // indirect
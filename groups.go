@@ -0,0 +1,107 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"sync"
+)
+
+var singles = flag.Int("singles", 1, "Most entries a site can have in a day and still be put with the singles, rather than get its own card")
+
+// groups holds the group each feed is in, like News, by the URL the feed
+// is listed as, and the groups in the order they're first given.
+// They're given in the feeds file, like group=News.
+var groups = struct {
+	sync.Mutex
+	byFeed map[string]string
+	order  []string
+}{
+	byFeed: map[string]string{},
+}
+
+// A Group is the cards of a day from feeds in the same group.
+// Those from feeds in none are in the one with no name.
+type Group struct {
+	Name string
+	Daily
+}
+
+// Groups is the day's cards by group: those in none first,
+// then each group in the order given, leaving out those with no entries.
+func (d Daily) Groups() []Group {
+	groups.Lock()
+	byFeed := groups.byFeed
+	order := append([]string{""}, groups.order...)
+	groups.Unlock()
+
+	byName := map[string]*Group{}
+	for _, name := range order {
+		g := Group{Name: name, Daily: d}
+		g.Singles, g.Sites = nil, nil
+		byName[name] = &g
+	}
+	for _, e := range d.Singles {
+		g := byName[byFeed[e.Source]]
+		g.Singles = append(g.Singles, e)
+	}
+	for _, s := range d.Sites {
+		g := byName[byFeed[s.Source()]]
+		g.Sites = append(g.Sites, s)
+	}
+	var gs []Group
+	for _, name := range order {
+		if g := byName[name]; g.Singles != nil || g.Sites != nil {
+			gs = append(gs, *g)
+		}
+	}
+	return gs
+}
+
+// titles holds the names feeds are shown with instead of their own,
+// by the URL the feed is listed as. They're given in the feeds file,
+// like title="A Friend".
+var titles = struct {
+	sync.Mutex
+	byFeed map[string]string
+}{
+	byFeed: map[string]string{},
+}
+
+// retitle gives the entries of the feed listed as u its title, if it has one.
+func retitle(u string, entries []Entry) {
+	titles.Lock()
+	title := titles.byFeed[u]
+	titles.Unlock()
+	if title == "" {
+		return
+	}
+	for i := range entries {
+		entries[i].FeedName = title
+	}
+}
+
+// cards holds where feeds' entries are shown, if not by how many there are,
+// by the URL the feed is listed as: in their own card, or with the singles.
+// They're given in the feeds file, like card=own or card=singles.
+var cards = struct {
+	sync.Mutex
+	byFeed map[string]string
+}{
+	byFeed: map[string]string{},
+}
+
+// single reports whether the n entries of the feed listed as u
+// are shown with the singles.
+func single(u string, n int) bool {
+	cards.Lock()
+	card := cards.byFeed[u]
+	cards.Unlock()
+	switch card {
+	case "own":
+		return false
+	case "singles":
+		return true
+	}
+	return n <= *singles
+}
@@ -0,0 +1,141 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// A FeedHealth is how fetching a feed has been going, for the status page.
+type FeedHealth struct {
+	URL string
+	FeedStatus
+}
+
+// ID identifies the feed in links, like feed/ID.
+func (h FeedHealth) ID() string {
+	return feedID(h.URL)
+}
+
+// State sums up the feed's health: ok, failed, if the last fetch did,
+// failing, if enough have in a row, retired, or new, if it hasn't been
+// fetched yet.
+func (h FeedHealth) State() string {
+	switch {
+	case !h.Retired.IsZero():
+		return "retired"
+	case h.Failing():
+		return "failing"
+	case !h.OK():
+		return "failed"
+	case h.LastSuccess.IsZero():
+		return "new"
+	}
+	return "ok"
+}
+
+// Took is how long fetching the feed typically takes.
+func (h FeedHealth) Took() time.Duration {
+	return h.Typical().Took.Round(time.Millisecond)
+}
+
+// trouble orders feeds by how badly they're doing, the worst first.
+func (h FeedHealth) trouble() int {
+	switch h.State() {
+	case "retired":
+		return 0
+	case "failing":
+		return 1
+	case "failed":
+		return 2
+	}
+	return 3
+}
+
+// feedHealth is the health of every feed, those in trouble first.
+func feedHealth() []FeedHealth {
+	var hs []FeedHealth
+	for _, u := range subscribed() {
+		s := feedStatus(u)
+		s.LastSuccess = s.LastSuccess.In(zone)
+		s.LastFailure = s.LastFailure.In(zone)
+		s.Next = s.Next.In(zone)
+		hs = append(hs, FeedHealth{u, s})
+	}
+	slices.SortStableFunc(hs, func(a, b FeedHealth) int {
+		return cmp.Or(cmp.Compare(a.trouble(), b.trouble()), cmp.Compare(a.URL, b.URL))
+	})
+	return hs
+}
+
+// Title is what the feed is called on public pages: its title in
+// the feeds file, or else its host, since its URL may have a token in it.
+func (h FeedHealth) Title() string {
+	titles.Lock()
+	title := titles.byFeed[h.URL]
+	titles.Unlock()
+	if title != "" {
+		return title
+	}
+	if u, err := url.Parse(h.URL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return h.URL
+}
+
+// failedFeeds is the titles of the feeds whose last fetch failed,
+// leaving out those that are retired, since they aren't fetched anymore.
+// Why they failed is only on the status page, which is for the admin.
+func failedFeeds() []string {
+	var failed []string
+	for _, h := range feedHealth() {
+		if s := h.State(); s == "failed" || s == "failing" {
+			failed = append(failed, h.Title())
+		}
+	}
+	return failed
+}
+
+// showStatus shows how fetching each feed has been going.
+func showStatus(w http.ResponseWriter) {
+	statusPage.Execute(w, feedHealth())
+}
+
+var statusPage = newPage("status", statusPageTemplate)
+
+var statusPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Status</title>
+</head>
+
+<body>
+		<nav class="details"><a href="./">today</a> · {{len .}} feeds · <a href="search">search</a></nav>
+		<table class="status">
+			<tr class="details"><th>Feed</th><th>State</th><th>Last fetched</th><th>HTTP</th><th>Entries</th><th>Takes</th><th>Next</th></tr>
+{{range .}}
+			<tr class="{{.State}}">
+				<td><a href="feed/{{.ID}}">{{.URL}}</a>{{if .LastError}}<br><span class="details">{{.LastError}}, {{.LastFailure.Format "2 Jan 15:04"}}</span>{{end}}</td>
+				<td>{{.State}}</td>
+				<td class="details">{{if .LastSuccess.IsZero}}never{{else}}{{.LastSuccess.Format "2 Jan 15:04"}}{{end}}</td>
+				<td class="details">{{with .StatusCode}}{{.}}{{end}}</td>
+				<td class="details">{{.Items}}</td>
+				<td class="details">{{with .Took}}{{.}}{{end}}</td>
+				<td class="details">{{if not .Next.IsZero}}{{.Next.Format "2 Jan 15:04"}}{{end}}</td>
+			</tr>
+{{end}}
+		</table>
+</body>
+</html>
+`
@@ -0,0 +1,175 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// parseHFeed reads h-entry microformats from an HTML page, for sites
+// that mark up their posts but don't publish a feed.
+// See https://microformats.org/wiki/h-entry.
+func parseHFeed(base *url.URL, r io.Reader) ([]Entry, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	feedName := ""
+	if t := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); t != nil {
+		feedName = textOf(t)
+	}
+	if hf := find(doc, hasClass("h-feed")); hf != nil {
+		if n := findOutside(hf, hasClass("p-name"), hasClass("h-entry")); n != nil {
+			feedName = textOf(n)
+		}
+	}
+
+	var entries []Entry
+	for _, he := range findAll(doc, hasClass("h-entry")) {
+		e := Entry{
+			FeedName: feedName,
+			FeedURL:  base.String(),
+		}
+
+		if n := find(he, hasClass("p-name")); n != nil {
+			e.Title = textOf(n)
+		} else if n := find(he, isHeading); n != nil {
+			e.Title = textOf(n)
+		}
+
+		if n := find(he, hasClass("u-url")); n != nil {
+			e.URL = firstOf(attrOf(n, "href"), attrOf(n, "src"), attrOf(n, "value"), textOf(n))
+		} else if n := find(he, func(n *html.Node) bool { return n.DataAtom == atom.A && attrOf(n, "href") != "" }); n != nil {
+			e.URL = attrOf(n, "href")
+		}
+
+		if n := find(he, hasClass("dt-published")); n != nil {
+			ts := firstOf(attrOf(n, "datetime"), attrOf(n, "value"), textOf(n))
+			if t, err := parseW3CTime(ts); err == nil {
+				e.When = t
+			} else if t, err := parseRssTimes(ts); err == nil {
+				e.When = t
+			}
+		}
+
+		if n := find(he, hasClass("p-author")); n != nil {
+			if name := find(n, hasClass("p-name")); name != nil {
+				n = name
+			}
+			e.Author = textOf(n)
+		}
+
+		if n := find(he, hasClass("p-summary")); n != nil {
+			e.Summary = excerpt(textOf(n), summaryLen)
+		} else if n := find(he, hasClass("e-content")); n != nil {
+			e.Summary = excerpt(textOf(n), summaryLen)
+		}
+		if e.Title == "" {
+			e.Title = excerpt(e.Summary, teaserLen)
+		}
+
+		for _, c := range findAll(he, hasClass("p-category")) {
+			e.Categories = append(e.Categories, textOf(c))
+		}
+
+		if e.URL != "" || e.Title != "" {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errNoFeed
+	}
+	return entries, nil
+}
+
+func hasClass(class string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		return n.Type == html.ElementNode && hasRel(attrOf(n, "class"), class)
+	}
+}
+
+func isHeading(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
+// find returns the first node under n, in document order, that matches.
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if match(c) {
+			return c
+		}
+		if f := find(c, match); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// findOutside is like find, but doesn't look inside nodes that match skip.
+func findOutside(n *html.Node, match, skip func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if skip(c) {
+			continue
+		}
+		if match(c) {
+			return c
+		}
+		if f := findOutside(c, match, skip); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// findAll returns the outermost nodes under n that match.
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if match(c) {
+			found = append(found, c)
+		} else {
+			found = append(found, findAll(c, match)...)
+		}
+	}
+	return found
+}
+
+func attrOf(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// textOf is the text within n, single-spaced.
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.DataAtom == atom.Script || n.DataAtom == atom.Style:
+			return
+		case n.Type == html.ElementNode:
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,138 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hnAPI is the official Hacker News API.
+// See https://github.com/HackerNews/API.
+const hnAPI = "https://hacker-news.firebaseio.com/v0/"
+
+// hnAPIHost is the host of the hnAPI, which isn't held to the -host-delay,
+// since it's made to be sent a request for each story.
+const hnAPIHost = "hacker-news.firebaseio.com"
+
+// hnStories is how many stories are taken from the top of a list.
+const hnStories = 30
+
+// hnFetchers is how many stories of a list are fetched at once.
+const hnFetchers = 8
+
+// hnLists maps the lists a feed can name, like hn:top, to their
+// API endpoints, web pages, and titles.
+var hnLists = map[string]struct{ api, page, title string }{
+	"top":  {"topstories", "news", "Hacker News"},
+	"best": {"beststories", "best", "Hacker News Best"},
+	"new":  {"newstories", "newest", "Hacker News New"},
+	"ask":  {"askstories", "ask", "Ask HN"},
+	"show": {"showstories", "show", "Show HN"},
+}
+
+// HNItem is a story from the Hacker News API.
+type HNItem struct {
+	ID          int    `json:"id"`
+	Type        string `json:"type"`
+	By          string `json:"by"`
+	Time        int64  `json:"time"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Text        string `json:"text"`
+	Score       int    `json:"score"`
+	Descendants int    `json:"descendants"`
+	Dead        bool   `json:"dead"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// isHN reports whether s names a Hacker News list rather than a feed URL.
+func isHN(s string) bool {
+	return strings.HasPrefix(s, "hn:")
+}
+
+// loadHN gets the stories at the top of the Hacker News list named by s,
+// like hn:top or hn:best.
+func loadHN(ctx context.Context, s string, a access) ([]Entry, Meta, error) {
+	name := strings.TrimPrefix(s, "hn:")
+	list, ok := hnLists[name]
+	if !ok {
+		return nil, Meta{}, fmt.Errorf("no Hacker News list called %q", name)
+	}
+
+	var ids []int
+	err := getJSON(ctx, hnAPI+list.api+".json", a, &ids)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	ids = ids[:min(hnStories, len(ids))]
+
+	items := make([]HNItem, len(ids))
+	errs := make(chan error)
+	turns := make(chan struct{}, hnFetchers)
+	for i, id := range ids {
+		go func(i, id int) {
+			turns <- struct{}{}
+			defer func() { <-turns }()
+			errs <- getJSON(ctx, hnAPI+"item/"+strconv.Itoa(id)+".json", a, &items[i])
+		}(i, id)
+	}
+	for range ids {
+		if e := <-errs; e != nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	var entries []Entry
+	for _, i := range items {
+		if i.Dead || i.Deleted || i.Type != "story" {
+			continue
+		}
+		thread := "https://news.ycombinator.com/item?id=" + strconv.Itoa(i.ID)
+		entries = append(entries, Entry{
+			FeedName:     list.title,
+			FeedURL:      "https://news.ycombinator.com/" + list.page,
+			Title:        i.Title,
+			URL:          firstOf(i.URL, thread),
+			Author:       i.By,
+			Summary:      excerpt(i.Text, summaryLen),
+			When:         time.Unix(i.Time, 0).UTC(),
+			GUID:         strconv.Itoa(i.ID),
+			Comments:     thread,
+			Score:        i.Score,
+			CommentCount: i.Descendants,
+		})
+	}
+	cleanTitles(entries)
+	return entries, Meta{Status: http.StatusOK}, nil
+}
+
+// getJSON decodes the JSON at u into v, asking for it with a.
+func getJSON(ctx context.Context, u string, a access, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	a.apply(req)
+	client, err := a.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: server said %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,214 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// maxIconSize is the biggest favicon worth keeping.
+const maxIconSize = 256 << 10
+
+// iconRetry is how long to wait before trying a site that had no usable icon.
+const iconRetry = 24 * time.Hour
+
+// iconExts are the kinds of icons that are kept, by their media types.
+// SVG isn't one, since it can have scripts in it.
+var iconExts = map[string]string{
+	"image/png":                ".png",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"image/gif":                ".gif",
+	"image/jpeg":               ".jpg",
+	"image/webp":               ".webp",
+}
+
+// iconHandler serves the icons kept on disk. They're from other sites,
+// so they're sandboxed and served as what they're named, in case one
+// has a script in it.
+func iconHandler() http.Handler {
+	files := http.FileServer(http.Dir(iconDir()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// icons tracks the favicons cached on disk, by site host.
+var icons = struct {
+	sync.Mutex
+	files  map[string]string
+	failed map[string]time.Time
+}{
+	files:  map[string]string{},
+	failed: map[string]time.Time{},
+}
+
+// iconDir is where favicons are kept, next to the feed cache.
+func iconDir() string {
+	return filepath.Join(filepath.Dir(*cache), "icons")
+}
+
+// loadIcons learns which favicons are already on disk.
+func loadIcons() {
+	des, err := os.ReadDir(iconDir())
+	if err != nil {
+		return
+	}
+	icons.Lock()
+	defer icons.Unlock()
+	for _, de := range des {
+		name := de.Name()
+		host := strings.TrimSuffix(name, filepath.Ext(name))
+		icons.files[host] = name
+	}
+}
+
+// iconPath returns the path the favicon for the site at siteURL is served from,
+// or the empty string if there isn't one.
+func iconPath(siteURL string) string {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	icons.Lock()
+	defer icons.Unlock()
+	if f, ok := icons.files[u.Host]; ok {
+		return "/icons/" + f
+	}
+	return ""
+}
+
+// fetchIcons gets the favicon of each site in entries that doesn't have one yet.
+func fetchIcons(entries []Entry) {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		u, err := url.Parse(e.FeedURL)
+		if err != nil || u.Host == "" || seen[u.Host] {
+			continue
+		}
+		seen[u.Host] = true
+
+		icons.Lock()
+		_, have := icons.files[u.Host]
+		last, failed := icons.failed[u.Host]
+		icons.Unlock()
+		if have || (failed && time.Since(last) < iconRetry) {
+			continue
+		}
+
+		name, err := saveIcon(u)
+		icons.Lock()
+		if err != nil {
+			icons.failed[u.Host] = time.Now()
+			log.Printf("No icon for %s: %v\n", u.Host, err)
+		} else {
+			icons.files[u.Host] = name
+			delete(icons.failed, u.Host)
+		}
+		icons.Unlock()
+	}
+}
+
+// saveIcon finds, downloads, and stores the favicon for site,
+// returning the name of its file in iconDir.
+func saveIcon(site *url.URL) (string, error) {
+	candidates := []string{}
+	if u, err := findIcon(site); err == nil {
+		candidates = append(candidates, u)
+	}
+	fallback := url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/favicon.ico"}
+	candidates = append(candidates, fallback.String())
+
+	var err error
+	for _, c := range candidates {
+		var name string
+		name, err = downloadIcon(site.Host, c)
+		if err == nil {
+			return name, nil
+		}
+	}
+	return "", err
+}
+
+// findIcon looks for a <link rel="icon"> in the site's page.
+func findIcon(site *url.URL) (string, error) {
+	resp, err := newClient().Get(site.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if !isHTML(resp.Header.Get("Content-Type")) {
+		return "", errors.New("not a web page")
+	}
+
+	z := html.NewTokenizer(resp.Body)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return "", errors.New("page doesn't link to an icon")
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "body" {
+				return "", errors.New("page doesn't link to an icon")
+			}
+			if t.Data == "link" && hasRel(attr(t, "rel"), "icon") && attr(t, "href") != "" {
+				u, err := resp.Request.URL.Parse(attr(t, "href"))
+				if err != nil {
+					return "", err
+				}
+				return u.String(), nil
+			}
+		}
+	}
+}
+
+func downloadIcon(host, iconURL string) (string, error) {
+	resp, err := newClient().Get(iconURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", iconURL, resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxIconSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxIconSize {
+		return "", fmt.Errorf("%s: icon is too big", iconURL)
+	}
+
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	ext, ok := iconExts[mt]
+	if !ok {
+		mt, _, _ = mime.ParseMediaType(http.DetectContentType(b))
+		ext, ok = iconExts[mt]
+	}
+	if !ok {
+		return "", fmt.Errorf("%s: %q isn't an icon", iconURL, mt)
+	}
+
+	err = os.MkdirAll(iconDir(), 0755)
+	if err != nil {
+		return "", err
+	}
+	name := host + ext
+	return name, os.WriteFile(filepath.Join(iconDir(), name), b, 0644)
+}
@@ -0,0 +1,366 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var importOPML = flag.String("import-opml", "", "Add the feeds in this OPML file, like one exported from Miniflux or Tiny Tiny RSS, to the -feeds file, and exit")
+var importMiniflux = flag.String("import-miniflux", "", "Add the entries in this Miniflux JSON export to the store, keeping which are read and starred, and exit. Without -archive, only starred ones are added")
+
+// opmlOutline is a feed, or a folder of them, in an OPML file.
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr"`
+	XMLURL   string        `xml:"xmlUrl,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// An opmlFeed is a feed in an OPML file, and the folder it's in, if any.
+// Folders in folders are named like Tech/Go.
+type opmlFeed struct {
+	URL, Group string
+}
+
+// isOPML reports whether b is an OPML file, rather than a list of feeds,
+// whose lines start with URLs.
+func isOPML(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("<"))
+}
+
+// parseOPML is the feeds in the OPML file b, in order.
+func parseOPML(b []byte) ([]opmlFeed, error) {
+	var opml struct {
+		Outlines []opmlOutline `xml:"body>outline"`
+	}
+	if err := xml.Unmarshal(b, &opml); err != nil {
+		return nil, err
+	}
+	var feeds []opmlFeed
+	var walk func(string, []opmlOutline)
+	walk = func(group string, outlines []opmlOutline) {
+		for _, o := range outlines {
+			if o.XMLURL != "" {
+				feeds = append(feeds, opmlFeed{o.XMLURL, group})
+				continue
+			}
+			name := firstOf(o.Text, o.Title)
+			if group != "" {
+				name = group + "/" + name
+			}
+			walk(name, o.Outlines)
+		}
+	}
+	walk("", opml.Outlines)
+	return feeds, nil
+}
+
+// importFeeds adds the feeds in the OPML file at name to the -feeds file.
+func importFeeds(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	opml, err := parseOPML(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	added, err := addFeeds(opml)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %d of %d feeds to %s.\n", len(added), len(opml), *feeds)
+	return nil
+}
+
+// addFeeds adds feeds to the end of the -feeds file, in their groups,
+// leaving out those it already lists, and returns the URLs of those it added.
+func addFeeds(opml []opmlFeed) ([]string, error) {
+	if *feeds == "" {
+		return nil, errors.New("I need a -feeds file to add the feeds to")
+	}
+	feedsFileLock.Lock()
+	defer feedsFileLock.Unlock()
+	b, err := readFile(*feeds)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if isOPML(b) {
+		return nil, fmt.Errorf("%s is OPML, which I can read, but not add to", *feeds)
+	}
+	have := map[string]bool{}
+	for _, l := range strings.Split(string(b), "\n") {
+		if f := splitQuoted(l); len(f) > 0 {
+			have[f[0]] = true
+		}
+	}
+
+	list := bytes.NewBuffer(b)
+	if len(b) > 0 && !bytes.HasSuffix(b, []byte("\n")) {
+		list.WriteString("\n")
+	}
+	var added []string
+	for _, f := range opml {
+		if have[f.URL] {
+			continue
+		}
+		have[f.URL] = true
+		if f.Group != "" {
+			// Quotes can't be in a quoted setting.
+			fmt.Fprintf(list, "%s group=\"%s\"\n", f.URL, strings.ReplaceAll(f.Group, `"`, ""))
+		} else {
+			fmt.Fprintln(list, f.URL)
+		}
+		added = append(added, f.URL)
+	}
+	if err := saveFeedsFile(list.Bytes()); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// minifluxEntry is an entry as Miniflux's API gives it.
+type minifluxEntry struct {
+	Status      string    `json:"status"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	CommentsURL string    `json:"comments_url"`
+	PublishedAt time.Time `json:"published_at"`
+	Content     string    `json:"content"`
+	Author      string    `json:"author"`
+	Starred     bool      `json:"starred"`
+	Tags        []string  `json:"tags"`
+	Enclosures  []struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+		Size     int64  `json:"size"`
+	} `json:"enclosures"`
+	Feed struct {
+		Title   string `json:"title"`
+		SiteURL string `json:"site_url"`
+		FeedURL string `json:"feed_url"`
+	} `json:"feed"`
+}
+
+// readMiniflux reads the entries in the file at name, which is either
+// what Miniflux's API gives for /v1/entries, or just the list of them.
+// Entries removed in Miniflux are left out.
+func readMiniflux(name string) ([]Entry, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var export struct {
+		Entries []minifluxEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(b, &export.Entries); err != nil {
+		if err := json.Unmarshal(b, &export); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	var entries []Entry
+	for _, m := range export.Entries {
+		if m.Status == "removed" {
+			continue
+		}
+		var encs []Enclosure
+		for _, enc := range m.Enclosures {
+			encs = append(encs, Enclosure{enc.URL, enc.MimeType, enc.Size})
+		}
+		entries = append(entries, Entry{
+			FeedName:   m.Feed.Title,
+			FeedURL:    m.Feed.SiteURL,
+			Title:      m.Title,
+			URL:        m.URL,
+			Author:     m.Author,
+			Summary:    excerpt(m.Content, summaryLen),
+			When:       m.PublishedAt,
+			Enclosures: encs,
+			Categories: m.Tags,
+			Comments:   m.CommentsURL,
+			Source:     m.Feed.FeedURL,
+			Read:       m.Status == "read",
+			Starred:    m.Starred,
+			Content:    htmlContent(m.Content),
+		})
+	}
+	cleanTitles(entries)
+	addTitles(entries)
+	return entries, nil
+}
+
+// htmlContent is the text of an entry's HTML content, in paragraphs
+// separated by blank lines, if there's more of it than its summary shows.
+func htmlContent(s string) string {
+	if len(plainText(s)) <= summaryLen {
+		return ""
+	}
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return ""
+	}
+	return strings.Join(paragraphs(doc), "\n\n")
+}
+
+// importEntries adds the entries in the Miniflux export at name to the store.
+// Ones it already has, from the same feed with the same link,
+// only take on whether they were read or starred.
+// The rest are added only if they'd be kept past the next fetch, like
+// any others: with -archive, or if they're starred.
+func importEntries(name string) error {
+	imported, err := readMiniflux(name)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	entries, err := st.Entries()
+	if err != nil {
+		return err
+	}
+
+	have := map[string]int{}
+	for i, e := range entries {
+		have[e.Source+"\x00"+e.URL] = i
+	}
+	added, skipped := 0, 0
+	for _, e := range imported {
+		if i, ok := have[e.Source+"\x00"+e.URL]; ok {
+			entries[i].Read = entries[i].Read || e.Read
+			entries[i].Starred = entries[i].Starred || e.Starred
+			entries[i].Content = firstOf(entries[i].Content, e.Content)
+			continue
+		}
+		if !*archive && !e.Starred {
+			skipped++
+			continue
+		}
+		entries = append(entries, e)
+		added++
+	}
+	if err := st.SaveEntries(dedupe(entries, nil)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %d of %d entries.\n", added, len(imported))
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d that aren't starred, which the next fetch would drop. Import them with -archive to keep them.\n", skipped)
+	}
+	return nil
+}
+
+// importAll does what the -import flags ask.
+func importAll() error {
+	if *importOPML != "" {
+		if err := importFeeds(*importOPML); err != nil {
+			return err
+		}
+	}
+	if *importMiniflux != "" {
+		return importEntries(*importMiniflux)
+	}
+	return nil
+}
+
+// importHandler adds the feeds in an OPML file posted to it as opml
+// to the -feeds file, and has them fetched, by sending one to refresh.
+// Otherwise, it's a form to post one with.
+func importHandler(refresh chan<- string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result struct {
+			Done         bool
+			Added, Given int
+			Error        string
+		}
+		if r.Method != http.MethodPost {
+			importPage.Execute(w, result)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+		f, _, err := r.FormFile("opml")
+		if err != nil {
+			http.Error(w, "I need an OPML file.", http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "I couldn't read the OPML file.", http.StatusBadRequest)
+			return
+		}
+
+		result.Done = true
+		opml, err := parseOPML(b)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			result.Error = "That isn't OPML: " + err.Error()
+			importPage.Execute(w, result)
+			return
+		}
+		added, err := addFeeds(opml)
+		if err != nil {
+			log.Printf("Couldn't import feeds: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			result.Error = err.Error()
+			importPage.Execute(w, result)
+			return
+		}
+		result.Added, result.Given = len(added), len(opml)
+		if len(added) > 0 {
+			// Refreshing any feed re-reads the feeds file,
+			// and the new ones are due, since they've never been fetched.
+			select {
+			case refresh <- added[0]:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		importPage.Execute(w, result)
+	})
+}
+
+var importPage = newPage("import", importPageTemplate)
+
+var importPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Import</title>
+</head>
+
+<body>
+		<nav class="details"><a href="./">today</a> · <a href="search">search</a></nav>
+		<h1>Import feeds</h1>
+{{if .Done}}{{with .Error}}		<p>{{.}}</p>
+{{else}}		<p>Added {{.Added}} of the {{.Given}} feeds. The new ones are being fetched.</p>
+{{end}}{{end}}		<form class="search" method="post" action="import" enctype="multipart/form-data">
+			<input type="file" name="opml" accept=".opml,.xml,text/x-opml,application/xml">
+			<button>Import</button>
+		</form>
+		<p class="details">Feeds in folders are put in groups named for them.</p>
+</body>
+</html>
+`
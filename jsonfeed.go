@@ -0,0 +1,117 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// JSONFeed is a JSON Feed, version 1.0 or 1.1.
+// See https://www.jsonfeed.org/version/1.1/.
+type JSONFeed struct {
+	Version string `json:"version"`
+	Title   string `json:"title"`
+	HomeURL string `json:"home_page_url"`
+	FeedURL string `json:"feed_url"`
+	Hubs    []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"hubs"`
+	Authors []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Author struct {
+		Name string `json:"name"`
+	} `json:"author"`
+
+	Items []struct {
+		ID          string   `json:"id"`
+		URL         string   `json:"url"`
+		ExternalURL string   `json:"external_url"`
+		Title       string   `json:"title"`
+		Summary     string   `json:"summary"`
+		ContentHTML string   `json:"content_html"`
+		ContentText string   `json:"content_text"`
+		Published   string   `json:"date_published"`
+		Modified    string   `json:"date_modified"`
+		Tags        []string `json:"tags"`
+		Authors     []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Author struct {
+			Name string `json:"name"`
+		} `json:"author"`
+		Attachments []struct {
+			URL    string `json:"url"`
+			Type   string `json:"mime_type"`
+			Length int64  `json:"size_in_bytes"`
+		} `json:"attachments"`
+	} `json:"items"`
+}
+
+func parseJSONFeed(r io.Reader) ([]Entry, Meta, error) {
+	var feed JSONFeed
+	err := json.NewDecoder(r).Decode(&feed)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	if !strings.Contains(feed.Version, "jsonfeed.org/version/") {
+		return nil, Meta{}, errors.New("JSON, but not a JSON Feed")
+	}
+
+	feedURL := feed.HomeURL
+	if feedURL == "" {
+		feedURL = feed.FeedURL
+	}
+
+	feedAuthor := feed.Author.Name
+	if len(feed.Authors) > 0 {
+		feedAuthor = feed.Authors[0].Name
+	}
+
+	var entries []Entry
+	for _, i := range feed.Items {
+		author := i.Author.Name
+		if len(i.Authors) > 0 {
+			author = i.Authors[0].Name
+		}
+		ts := pickDate(i.Published, i.Modified)
+		when, err := time.Parse(time.RFC3339, ts)
+		if err != nil && ts != "" {
+			log.Printf("Time parse error for %q: json feed gives %v\n", i.Title, err)
+		}
+		var encs []Enclosure
+		for _, a := range i.Attachments {
+			encs = append(encs, Enclosure{a.URL, a.Type, a.Length})
+		}
+		u := i.URL
+		if u == "" {
+			u = i.ExternalURL
+		}
+		entries = append(entries, Entry{
+			FeedName:   feed.Title,
+			FeedURL:    feedURL,
+			Title:      i.Title,
+			URL:        u,
+			Author:     firstOf(author, feedAuthor),
+			Summary:    excerpt(firstOf(i.Summary, i.ContentText, i.ContentHTML), summaryLen),
+			When:       when,
+			Enclosures: encs,
+			Categories: i.Tags,
+			GUID:       i.ID,
+		})
+	}
+	meta := Meta{Self: feed.FeedURL}
+	for _, h := range feed.Hubs {
+		if strings.EqualFold(h.Type, "WebSub") {
+			meta.Hub = h.URL
+			break
+		}
+	}
+	return entries, meta, nil
+}
@@ -0,0 +1,64 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// decodeFeed decodes the XML feed in b. Plenty of feeds aren't quite XML,
+// so if b is malformed, decodeFeed scrubs it and tries again more forgivingly
+// before giving up with the original error.
+func decodeFeed(b []byte) (Feed, error) {
+	feed, err := decodeXML(bytes.NewReader(b), true)
+	if err == nil {
+		return feed, nil
+	}
+	feed, lerr := decodeXML(bytes.NewReader(scrub(b)), false)
+	if lerr != nil {
+		return Feed{}, err
+	}
+	return feed, nil
+}
+
+func decodeXML(r io.Reader, strict bool) (Feed, error) {
+	var feed Feed
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+	if !strict {
+		d.Strict = false
+		d.AutoClose = xml.HTMLAutoClose
+		d.Entity = xml.HTMLEntity
+	}
+	err := d.Decode(&feed)
+	return feed, err
+}
+
+var xmlEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*encoding=["']([^"']+)["']`)
+
+// scrub drops the control characters XML doesn't allow and, if the document
+// claims to be UTF-8, replaces any invalid UTF-8 in it.
+func scrub(b []byte) []byte {
+	clean := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c >= 0x20 || c == '\t' || c == '\n' || c == '\r' {
+			clean = append(clean, c)
+		}
+	}
+	b = clean
+
+	enc := "utf-8"
+	if m := xmlEncoding.FindSubmatch(b); m != nil {
+		enc = string(bytes.ToLower(m[1]))
+	}
+	if (enc == "utf-8" || enc == "utf8") && !utf8.Valid(b) {
+		b = bytes.ToValidUTF8(b, []byte("�"))
+	}
+	return b
+}
@@ -0,0 +1,84 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// resolve returns ref resolved against base. If either can't be used,
+// ref is returned untouched.
+func resolve(base *url.URL, ref string) string {
+	if base == nil || ref == "" {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// rebase returns the base URL in effect inside an element with
+// the given xml:base attribute.
+func rebase(base *url.URL, xmlBase string) *url.URL {
+	if xmlBase == "" {
+		return base
+	}
+	if base == nil {
+		u, err := url.Parse(xmlBase)
+		if err != nil {
+			return nil
+		}
+		return u
+	}
+	u, err := base.Parse(xmlBase)
+	if err != nil {
+		return base
+	}
+	return u
+}
+
+// resolveLinks makes the URLs of entries absolute, relative to where
+// their feed was fetched from.
+func resolveLinks(base *url.URL, entries []Entry) {
+	for i := range entries {
+		e := &entries[i]
+		e.FeedURL = resolve(base, e.FeedURL)
+		e.URL = resolve(base, e.URL)
+		e.Artwork = resolve(base, e.Artwork)
+		e.Thumbnail = resolve(base, e.Thumbnail)
+		e.Comments = resolve(base, e.Comments)
+		for j := range e.Enclosures {
+			e.Enclosures[j].URL = resolve(base, e.Enclosures[j].URL)
+		}
+	}
+}
+
+// canonicalURL reduces u to a form that's the same for trivially different
+// links to one page: no scheme, "www.", fragment, tracking parameters,
+// default port, or trailing slash, and a lowercase host.
+func canonicalURL(u string) string {
+	p, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || p.Host == "" {
+		return u
+	}
+	host := strings.ToLower(p.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := p.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	q := p.Query()
+	for k := range q {
+		if strings.HasPrefix(k, "utm_") || k == "fbclid" || k == "gclid" {
+			q.Del(k)
+		}
+	}
+	s := host + strings.TrimSuffix(p.EscapedPath(), "/")
+	if len(q) > 0 {
+		s += "?" + q.Encode()
+	}
+	return s
+}
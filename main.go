@@ -4,47 +4,88 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"cmp"
-	"encoding/gob"
+	"context"
 	"encoding/xml"
 	"errors"
 	"flag"
-	"html/template"
+	"fmt"
 	"io"
 	"io/fs"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"slices"
+	"strings"
+	"syscall"
 	"time"
 )
 
-var feeds = flag.String("feeds", "", "file containing a list of feeds")
+var feeds = flag.String("feeds", "", "file containing a list of feeds, or an OPML file of them")
 var cert = flag.String("cert", "", "Certificate file")
 var key = flag.String("key", "", "Private key for certificate")
 var cache = flag.String("cache", "rss.gob", "File for storing feed results")
-var freq = flag.Duration("freq", 1*time.Hour, "Duration between feed polls")
+var store = flag.String("store", "", "Where to keep entries instead of the -cache file: sqlite:path, bolt:path, or s3:bucket/key to copy it to a bucket")
+var freq = flag.Duration("freq", 1*time.Hour, "Duration between feed polls, for feeds without their own")
 var httpAddr = flag.String("http", ":http", "HTTP listen address (in typical Dial fashion)")
+var fetchers = flag.Int("fetchers", 8, "Number of feeds to fetch at once")
+var dateBy = flag.String("date", "updated", "Date to go by for entries that have both: updated or published")
 
 func main() {
 	flag.Parse()
+	maybeDie(loadSealKey())
+	maybeDie(loadZone())
+
+	if *sealFile != "" {
+		maybeDie(sealInPlace(*sealFile))
+		return
+	}
+	if *unsealFile != "" {
+		maybeDie(unsealTo(*unsealFile))
+		return
+	}
+	if *exportFormat != "" {
+		maybeDie(exportStore())
+		return
+	}
+	if *importOPML != "" || *importMiniflux != "" {
+		maybeDie(importAll())
+		return
+	}
 
 	if flag.NArg() == 0 && *feeds == "" {
 		os.Stderr.WriteString("I need the feed URL.\n")
 		os.Exit(1)
 	}
-
-	var urls []string
-	if flag.NArg() > 0 {
-		urls = append(urls, flag.Args()...)
+	if *dateBy != "updated" && *dateBy != "published" {
+		os.Stderr.WriteString("The date must be updated or published.\n")
+		os.Exit(1)
+	}
+	if *fetchers < 1 {
+		os.Stderr.WriteString("I need at least one fetcher.\n")
+		os.Exit(1)
 	}
+	if *freq <= 0 || *minFreq <= 0 || *maxFreq <= 0 {
+		os.Stderr.WriteString("The -freq, -min-freq, and -max-freq must be positive.\n")
+		os.Exit(1)
+	}
+	_, err := proxyFunc()
+	maybeDie(err)
+	_, err = tlsOptions{}.config()
+	maybeDie(err)
+	if *restore != "" {
+		maybeDie(restoreBackup(*restore))
+	}
+	st, err := openStore()
+	maybeDie(err)
+	defer st.Close()
 
 	if *feeds != "" {
-		f, err := os.Open(*feeds)
-		maybeDie(err)
-
-		finfo, err := f.Stat()
+		finfo, err := os.Stat(*feeds)
 		maybeDie(err)
 		cinfo, err := os.Stat(*cache)
 		if !errors.Is(err, fs.ErrNotExist) {
@@ -52,48 +93,159 @@ func main() {
 		} else if cinfo != nil && finfo.ModTime().After(cinfo.ModTime()) {
 			os.Remove(*cache)
 		}
-
-		in := bufio.NewScanner(f)
-		for in.Scan() {
-			urls = append(urls, in.Text())
-		}
-		f.Close()
-		maybeDie(in.Err())
 	}
+	urls, err := readFeeds()
+	maybeDie(err)
 
 	toSave := make(chan []Entry)
+	toAdd := make(chan []Entry)
 	toShow := make(chan []Entry)
-	go feedCache(toSave, toShow)
-	go fetchFeeds(toSave, urls)
+	toMark := make(chan mark)
+	toCompact := make(chan struct{})
+	maybeDie(loadPages())
+	loadIcons()
+	loadStatuses()
+	loadCookies()
+	loadMutes()
+	go feedCache(st, toSave, toAdd, toMark, toCompact, toShow)
+	refresh := make(chan string, 16)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *templateDir != "" {
+		go watchPages(ctx)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		fetchFeeds(ctx, st, toSave, toShow, toMark, refresh, urls)
+		close(stopped)
+	}()
 
-	http.Handle("/style/", http.StripPrefix("/style/", http.FileServer(http.Dir("style/"))))
+	http.Handle("/style/", http.StripPrefix("/style/", styleHandler()))
+	http.HandleFunc("/theme.css", themeStyle)
+	http.HandleFunc("/theme", themeHandler)
+	http.HandleFunc("/sw.js", serviceWorker)
+	http.HandleFunc("/mute", muteHandler)
+	http.Handle("/icons/", http.StripPrefix("/icons/", iconHandler()))
+	http.Handle("/websub/", http.StripPrefix("/websub/", websubHandler(toAdd)))
+	http.Handle("/read", readHandler(toMark))
+	http.Handle("/star", starHandler(toMark))
+	http.HandleFunc("/unread", func(w http.ResponseWriter, r *http.Request) {
+		showUnread(w, r, toShow)
+	})
+	http.HandleFunc("/starred", func(w http.ResponseWriter, r *http.Request) {
+		showStarred(w, toShow)
+	})
+	http.Handle("/search", searchHandler(st))
+	http.Handle("/export", exportHandler(toShow))
+	http.Handle("/compact", adminOnly(compactHandler(toCompact)))
+	http.Handle("/status", adminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		showStatus(w)
+	})))
+	http.Handle("/admin", adminOnly(adminHandler(refresh)))
+	http.Handle("/admin/backup", adminOnly(backupHandler(st)))
+	http.Handle("/import", adminOnly(importHandler(refresh)))
+	http.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		showArticle(w, r, toShow)
+	})
+	http.Handle("/refresh", adminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Refreshing takes a POST.", http.StatusMethodNotAllowed)
+			return
+		}
+		select {
+		case refresh <- r.FormValue("feed"):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "Refreshing.\n")
+	})))
 	http.HandleFunc("/day", func(w http.ResponseWriter, r *http.Request) {
-		showDaily(w, time.Now().UTC().AddDate(0, 0, -1), toShow)
+		if r.FormValue("date") != "" {
+			showDay(w, r, toShow)
+			return
+		}
+		showDaily(w, latestDay(), dayView{page: pageOf(r)}, toShow)
+	})
+	http.HandleFunc("/day/", func(w http.ResponseWriter, r *http.Request) {
+		showDay(w, r, toShow)
+	})
+	http.HandleFunc("/week", func(w http.ResponseWriter, r *http.Request) {
+		showWeek(w, r, toShow)
+	})
+	http.HandleFunc("/week/", func(w http.ResponseWriter, r *http.Request) {
+		showWeek(w, r, toShow)
+	})
+	http.HandleFunc("/month", func(w http.ResponseWriter, r *http.Request) {
+		showMonth(w, r, toShow)
+	})
+	http.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		showFeed(w, r, toShow)
+	})
+	http.HandleFunc("/feed/", func(w http.ResponseWriter, r *http.Request) {
+		showFeed(w, r, toShow)
+	})
+	http.HandleFunc("/feed.atom", func(w http.ResponseWriter, r *http.Request) {
+		showAtom(w, r, toShow)
 	})
 	http.HandleFunc("/yesterday", func(w http.ResponseWriter, r *http.Request) {
-		t := time.Now().UTC().AddDate(0, 0, -2)
-		showDaily(w, t, toShow)
+		showDaily(w, latestDay().AddDate(0, 0, -1), dayView{page: pageOf(r)}, toShow)
 	})
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
-			showDaily(w, time.Now().UTC().AddDate(0, 0, -1), toShow)
+			showDaily(w, latestDay(), dayView{page: pageOf(r)}, toShow)
 		} else {
 			http.NotFound(w, r)
 		}
 	})
-	if *cert != "" && *key != "" {
+	var servers []*http.Server
+	serve := func(srv *http.Server, listen func() error) {
+		servers = append(servers, srv)
 		go func() {
-			err := http.ListenAndServeTLS(":https", *cert, *key, nil)
-			log.Println(err)
+			err := listen()
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Println(err)
+				stop()
+			}
 		}()
 	}
-	http.ListenAndServe(*httpAddr, nil)
+	if *cert != "" && *key != "" {
+		srv := &http.Server{Addr: ":https"}
+		serve(srv, func() error { return srv.ListenAndServeTLS(*cert, *key) })
+	}
+	srv := &http.Server{Addr: *httpAddr}
+	serve(srv, srv.ListenAndServe)
+
+	<-ctx.Done()
+	log.Println("Shutting down.")
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, srv := range servers {
+		srv.Shutdown(shutdown)
+	}
+	<-stopped
 }
 
-func showDaily(w io.Writer, day time.Time, fc <-chan []Entry) {
-	feeds := <-fc
-	entries := filterEntries(feeds, day, day.AddDate(0, 0, 1))
+// showDaily shows a page of the entries of the day starting at day.
+func showDaily(w io.Writer, day time.Time, v dayView, fc <-chan []Entry) {
+	d := daily(<-fc, day)
+	d.Base, d.Link = v.base, v.link
+	d.paginate(v.page)
+	dailyPage.Execute(w, d)
+}
+
+// daily groups the entries of the day starting at day by site.
+func daily(feeds []Entry, day time.Time) Daily {
+	d := bySite(collapse(unmuted(filterEntries(feeds, day, day.AddDate(0, 0, 1)))))
+	d.Day = day
+	return d
+}
 
+// bySite groups entries by site, for showing as cards. Sites with no more
+// than -singles entries are put together as singles, unless their feeds
+// are set to get their own card, or to always be with the singles.
+func bySite(entries []Entry) Daily {
 	sites := map[string][]Entry{}
 	for i := range entries {
 		sites[entries[i].FeedName] = append(sites[entries[i].FeedName], entries[i])
@@ -101,86 +253,187 @@ func showDaily(w io.Writer, day time.Time, fc <-chan []Entry) {
 
 	var d Daily
 	for s := range sites {
-		if len(sites[s]) == 1 {
-			d.Singles = append(d.Singles, sites[s][0])
+		if single(sites[s][0].Source, len(sites[s])) {
+			d.Singles = append(d.Singles, sites[s]...)
 		} else {
 			d.Sites = append(d.Sites, Site{s, sites[s]})
 		}
 	}
+	slices.SortFunc(d.Singles, func(a, b Entry) int {
+		return cmp.Or(cmp.Compare(a.FeedName, b.FeedName), b.When.Compare(a.When))
+	})
 	slices.SortFunc(d.Sites, func(a, b Site) int {
 		return cmp.Compare(a.Name, b.Name)
 	})
-
-	dailyPage.Execute(w, d)
+	return d
 }
 
-func feedCache(toSave <-chan []Entry, toShow chan<- []Entry) {
+// feedCache holds the entries. Those from toSave replace all but the starred
+// ones, or with -archive, are added to them all. Those from toAdd, such as
+// pushed updates to one feed, are merged in. Either way, only those that
+// -keep and -keep-per-feed allow are kept. Marks from toMark change them,
+// like marking them read.
+// Every so often, old ones are pruned, and the store is compacted,
+// which toCompact can ask for, too.
+func feedCache(st Store, toSave, toAdd <-chan []Entry, toMark <-chan mark, toCompact <-chan struct{}, toShow chan<- []Entry) {
 	var feedz []Entry
+	pruneTime := pruning()
+	compactTime := compacting()
 	for {
 		select {
 		case toShow <- feedz:
 			// I just sent it.
-		case feedz = <-toSave:
-			saveFeeds(feedz)
+		case <-pruneTime:
+			feedz = prune(st, feedz)
+		case <-compactTime:
+			compact(st)
+		case <-toCompact:
+			compact(st)
+		case next := <-toSave:
+			feedz = refetched(next, feedz)
+			saveFeeds(st, feedz)
+		case more := <-toAdd:
+			feedz = retain(dedupe(append(more, feedz...), feedz))
+			saveFeeds(st, feedz)
+		case m := <-toMark:
+			feedz = m.apply(feedz)
+			saveFeeds(st, feedz)
 		}
 	}
 }
 
-func saveFeeds(feeds []Entry) {
-	f, err := os.Create(*cache)
-	maybeDie(err)
-	defer f.Close()
+// refetched is feedz with next, the entries just fetched, in place of all
+// but those carried over. What's beyond -keep or -keep-per-feed is left out
+// here, too, not just when pruning, or else entries that feeds still have
+// would come back as new, unread ones after each prune.
+func refetched(next, feedz []Entry) []Entry {
+	return retain(dedupe(append(next, carried(feedz)...), feedz))
+}
 
-	enc := gob.NewEncoder(f)
-	enc.Encode(feeds)
+// saveFeeds writes feeds to st.
+func saveFeeds(st Store, feeds []Entry) {
+	if err := st.SaveEntries(feeds); err != nil {
+		log.Printf("Couldn't save the feeds: %v\n", err)
+	}
 }
 
-func fetchFeeds(db chan<- []Entry, urls []string) {
-	f, err := os.Open(*cache)
-	if err != nil {
-		fetch(db, urls)
+// fetchFeeds keeps db up to date with the feeds at urls, starting with
+// those saved in st, if any, and fetching each when it's due.
+// A URL from refresh makes its feed due now, even if it was
+// retired, after re-reading the feeds file in case it's new.
+// An empty one makes every feed due. URLs that aren't in
+// the feeds file are ignored.
+func fetchFeeds(ctx context.Context, st Store, db chan<- []Entry, cached <-chan []Entry, marks chan<- mark, refresh <-chan string, urls []string) {
+	feeds, err := st.Entries()
+	maybeDie(err)
+	if feeds == nil {
+		fetch(ctx, db, cached, marks, urls)
 	} else {
-		var feeds []Entry
-		dec := gob.NewDecoder(f)
-		err := dec.Decode(&feeds)
-		f.Close()
-		maybeDie(err)
+		loadValidators()
 		db <- feeds
+		stagger(urls)
 	}
 
-	tt := time.Tick(*freq)
-	for _ = range tt {
-		fetch(db, urls)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(untilDue(urls)):
+		case u := <-refresh:
+			if more, err := readFeeds(); err != nil {
+				log.Printf("Couldn't re-read the feeds: %v\n", err)
+			} else {
+				urls = more
+			}
+			if u == "" {
+				hurry(urls...)
+			} else if slices.Contains(urls, u) {
+				revive(u)
+				hurry(u)
+			} else {
+				log.Printf("Not refreshing %s, which isn't in the feeds file.\n", u)
+			}
+		}
+		fetch(ctx, db, cached, marks, urls)
 	}
 }
 
-func fetch(db chan<- []Entry, urls []string) {
-	log.Printf("It's time to fetch %d feeds.", len(urls))
-	n := 0
+// fetch gets the feeds at urls that are due and sends them to db,
+// along with the cached entries of those that aren't, haven't changed,
+// or couldn't be fetched this time. Then, with -extract, the articles of
+// new entries are extracted in the background, and marked by marks.
+// If ctx is done before they're all fetched, none are sent.
+func fetch(ctx context.Context, db chan<- []Entry, cached <-chan []Entry, marks chan<- mark, urls []string) {
+	var todo []string
 	var feeds []Entry
 	errs := []error{}
 	fc := make(chan []Entry)
 	ec := make(chan error)
+	keep := map[string]bool{}
 
 	for _, u := range urls {
 		if len(u) == 0 {
 			continue
 		}
+		if !due(u) || retired(u) {
+			keep[u] = true
+			continue
+		}
 
-		n++
-		go getFeed(u, fc, ec)
+		todo = append(todo, u)
 	}
+	log.Printf("It's time to fetch %d of %d feeds.", len(todo), len(urls))
 
-	for i := 0; i < n; i++ {
+	jobs := make(chan string)
+	go func() {
+		for _, u := range todo {
+			jobs <- u
+		}
+		close(jobs)
+	}()
+	for i := 0; i < min(*fetchers, len(todo)); i++ {
+		go func() {
+			for u := range jobs {
+				getFeed(ctx, u, fc, ec)
+			}
+		}()
+	}
+
+	for range todo {
 		select {
 		case f := <-fc:
 			feeds = append(feeds, f...)
 		case e := <-ec:
+			if u, ok := e.(unchanged); ok {
+				keep[string(u)] = true
+				continue
+			}
+			if fe, ok := e.(feedError); ok {
+				keep[fe.source] = true
+			}
 			errs = append(errs, e)
 		}
 	}
 
+	if ctx.Err() != nil {
+		log.Println("Fetching stopped.")
+		return
+	}
+
+	if len(keep) > 0 {
+		for _, e := range <-cached {
+			if keep[e.Source] {
+				feeds = append(feeds, e)
+			}
+		}
+	}
+
 	db <- feeds
+	go fetchIcons(feeds)
+	go extractArticles(ctx, <-cached, marks)
+	saveValidators()
+	saveStatuses()
+	saveCookies()
 
 	for _, e := range errs {
 		log.Printf("Problem: %v\n", e)
@@ -188,26 +441,167 @@ func fetch(db chan<- []Entry, urls []string) {
 	log.Println("Done fetching.")
 }
 
-func getFeed(s string, fc chan []Entry, ec chan error) {
-	url, err := url.Parse(s)
+// feedError is why the feed listed as source couldn't be fetched.
+type feedError struct {
+	source string
+	err    error
+}
+
+func (fe feedError) Error() string {
+	return fe.source + ": " + fe.err.Error()
+}
+
+func (fe feedError) Unwrap() error {
+	return fe.err
+}
+
+func getFeed(ctx context.Context, s string, fc chan []Entry, ec chan error) {
+	entries, meta, err := loadWithRetries(ctx, s)
+	if errors.Is(err, errNotModified) {
+		pace(s, 0)
+		fetched(s, http.StatusNotModified, 0)
+		ec <- unchanged(s)
+		return
+	}
 	if err != nil {
-		ec <- errors.New(s + ": " + err.Error())
+		wait := waitAsked(err)
+		if ctx.Err() == nil && failed(s, err) {
+			wait = max(wait, failingPace)
+		}
+		pace(s, wait)
+		ec <- feedError{s, err}
 		return
 	}
+	fetched(s, meta.Status, len(entries))
+	measured(s, meta)
+	fresh(s, meta.Fresh)
+	if meta.Moved != "" {
+		relocate(s, meta.Moved)
+	}
+	for i := range entries {
+		entries[i].Source = s
+	}
+	retitle(s, entries)
+	addOGImages(entries)
+	observe(s, entries)
+	if meta.Hub != "" {
+		go subscribe(s, meta.Self, meta.Hub)
+	}
+	pace(s, max(meta.Interval, meta.Fresh))
+	fc <- entries
+}
+
+// loadFeed fetches and parses the feed at s. If s is a web page and discover
+// is set, loadFeed follows the page's link to its feed instead.
+// If s names a Hacker News list, like hn:top, its stories come from the API.
+// Requests are made with a.
+func loadFeed(ctx context.Context, s string, a access, discover bool) ([]Entry, Meta, error) {
+	if isHN(s) {
+		return loadHN(ctx, s, a)
+	}
 
-	resp, err := http.Get(url.String())
+	url, err := url.Parse(s)
 	if err != nil {
-		ec <- errors.New(s + ": " + err.Error())
-		return
+		return nil, Meta{}, err
+	}
+	if discover {
+		url = redditListing(url)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url.String(), nil)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	a.apply(req)
+	askIfModified(req, url.String())
+
+	var movedTo string
+	client, err := a.client()
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	client.CheckRedirect = a.redirects(permanentRedirects(&movedTo))
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, Meta{}, err
 	}
 	defer resp.Body.Close()
 
-	entries, err := tryParse(resp.Body)
+	counted := &countingReader{r: resp.Body}
+	zbody, err := decompress(counted, resp.Header.Get("Content-Encoding"))
 	if err != nil {
-		ec <- errors.New(s + ": " + err.Error())
-		return
+		return nil, Meta{}, err
 	}
-	fc <- entries
+	body := bufio.NewReader(&sizeLimit{zbody, *maxSize})
+	peek, _ := body.Peek(512)
+	kind := sniffKind(peek)
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, Meta{}, errNotModified
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, Meta{}, statusError(resp, kind)
+	}
+
+	if discover && (isHTML(resp.Header.Get("Content-Type")) || kind == "HTML") {
+		page, err := io.ReadAll(body)
+		if err != nil {
+			return nil, Meta{}, err
+		}
+		took := time.Since(start)
+		found, err := discoverFeed(resp.Request.URL, bytes.NewReader(page))
+		if errors.Is(err, errNoFeed) {
+			entries, err := parseHFeed(resp.Request.URL, bytes.NewReader(page))
+			if err != nil {
+				return nil, Meta{}, err
+			}
+			cleanTitles(entries)
+			addTitles(entries)
+			resolveLinks(resp.Request.URL, entries)
+			return entries, Meta{
+				Self:    resp.Request.URL.String(),
+				Moved:   movedTo,
+				Status:  resp.StatusCode,
+				Took:    took,
+				Parsing: time.Since(start) - took,
+				Bytes:   counted.n,
+			}, nil
+		}
+		if err != nil {
+			return nil, Meta{}, err
+		}
+		if !sameSite(found, resp.Request.URL) {
+			a = a.public()
+		}
+		entries, meta, err := loadFeed(ctx, found, a, false)
+		meta.Moved = movedTo
+		meta.Took += took
+		meta.Bytes += counted.n
+		return entries, meta, err
+	}
+	if kind != "XML" && kind != "JSON" {
+		return nil, Meta{}, fmt.Errorf("server returned %s, not a feed", kind)
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	took := time.Since(start)
+	entries, meta, err := tryParse(resp.Request.URL, bytes.NewReader(b))
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	meta.Took = took
+	meta.Parsing = time.Since(start) - took
+	meta.Bytes = counted.n
+	meta.Self = firstOf(meta.Self, resp.Request.URL.String())
+	meta.Moved = movedTo
+	meta.Status = resp.StatusCode
+	meta.Fresh = freshFor(resp)
+	remember(url.String(), resp)
+	return entries, meta, nil
 }
 
 func maybeDie(err error) {
@@ -218,61 +612,252 @@ func maybeDie(err error) {
 }
 
 type Feed struct {
-	atom *Atom1
-	rss  *Rss2
+	atom   *Atom1
+	atom03 *Atom03
+	rss    *Rss2
+	rdf    *Rss1
 }
 
-func (f *Feed) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	if start.Name.Local == "rss" {
+const atom03NS = "http://purl.org/atom/ns#"
+
+// Meta is what a feed says about itself, rather than its entries.
+type Meta struct {
+	// Hub is the WebSub hub that pushes the feed's updates.
+	Hub string
+	// Self is the feed's own URL, according to the feed.
+	Self string
+	// Interval is how often the feed asks to be polled, if it does.
+	Interval time.Duration
+	// Fresh is how long the server said the feed could be cached.
+	Fresh time.Duration
+	// Moved is where the feed permanently redirected to, if it did.
+	Moved string
+	// Status is the HTTP status the feed was served with.
+	Status int
+	// Took, Parsing, and Bytes are what fetching the feed cost.
+	Took, Parsing time.Duration
+	Bytes         int64
+}
 
+func (f *Feed) meta() Meta {
+	var m Meta
+	var links []AtomLink
+	switch {
+	case f.atom != nil:
+		links = f.atom.Links
+	case f.rss != nil:
+		links = f.rss.Channel.AtomLinks
+		m.Interval = f.rss.Channel.Syndication.interval()
+		if f.rss.Channel.TTL > 0 {
+			m.Interval = time.Duration(f.rss.Channel.TTL) * time.Minute
+		}
+	case f.rdf != nil:
+		m.Interval = f.rdf.Channel.Syndication.interval()
+	}
+
+	for _, l := range links {
+		switch {
+		case hasRel(l.Rel, "hub") && m.Hub == "":
+			m.Hub = l.URL
+		case hasRel(l.Rel, "self") && m.Self == "":
+			m.Self = l.URL
+		}
+	}
+	return m
+}
+
+func (f *Feed) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "rss":
 		return d.DecodeElement(&f.rss, &start)
+	case "RDF":
+		return d.DecodeElement(&f.rdf, &start)
+	}
+	if start.Name.Space == atom03NS {
+		return d.DecodeElement(&f.atom03, &start)
 	}
 	return d.DecodeElement(&f.atom, &start)
 }
 
-func tryParse(r io.Reader) ([]Entry, error) {
-	var feed Feed
-	d := xml.NewDecoder(r)
-	err := d.Decode(&feed)
+// tryParse parses the feed in r, which was fetched from base.
+// Base may be nil if the feed's location isn't known.
+func tryParse(base *url.URL, r io.Reader) ([]Entry, Meta, error) {
+	entries, meta, err := parseFeed(base, r)
 	if err != nil {
-		return nil, err
+		return nil, Meta{}, err
+	}
+	cleanTitles(entries)
+	addTitles(entries)
+	resolveLinks(base, entries)
+	meta.Hub = resolve(base, meta.Hub)
+	meta.Self = resolve(base, meta.Self)
+	return entries, meta, nil
+}
+
+func parseFeed(base *url.URL, r io.Reader) ([]Entry, Meta, error) {
+	br := bufio.NewReader(r)
+	// The decoders don't skip a byte order mark themselves.
+	if bom, _ := br.Peek(3); string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+	if isJSON(br) {
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return nil, Meta{}, err
+		}
+		if isRedditListing(b) {
+			return parseRedditListing(base, b)
+		}
+		return parseJSONFeed(bytes.NewReader(b))
+	}
+
+	b, err := io.ReadAll(br)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	feed, err := decodeFeed(b)
+	if err != nil {
+		return nil, Meta{}, err
 	}
 	var entries []Entry
 
 	if feed.atom != nil {
+		base := rebase(base, feed.atom.Base)
 		for _, i := range feed.atom.Items {
-			when, err := time.Parse(time.RFC3339, i.When)
-			if err != nil {
+			ibase := rebase(base, i.Base)
+			var cats []string
+			for _, c := range i.Categories {
+				cats = append(cats, firstOf(c.Label, c.Term))
+			}
+			ts := pickDate(i.Published, i.Updated)
+			when, err := time.Parse(time.RFC3339, ts)
+			if err != nil && ts != "" {
 				log.Printf("Time parse error for %q: atom gives %v\n", i.Title, err)
 			}
 			entries = append(entries, Entry{
-				FeedName: feed.atom.Title,
-				FeedURL:  feed.atom.Link.URL,
+				FeedName:   feed.atom.Title,
+				FeedURL:    resolve(base, alternate(feed.atom.Links)),
+				Title:      i.Title,
+				URL:        firstOf(i.YouTube.watchURL(), resolve(ibase, alternate(i.Links))),
+				Author:     firstOf(i.Author, feed.atom.Author),
+				Summary:    excerpt(firstOf(i.Summary, i.Content, i.MediaRSS.Group.Description), summaryLen),
+				When:       when,
+				Enclosures: atomEnclosures(ibase, i.Links),
+				Comments:   resolve(ibase, replies(i.Links)),
+				Categories: cats,
+				GUID:       i.ID,
+				Thumbnail:  i.MediaRSS.thumbnail(),
+			})
+		}
+	} else if feed.atom03 != nil {
+		for _, i := range feed.atom03.Items {
+			ts := pickDate(i.Issued, i.Modified)
+			if ts == "" {
+				ts = i.Created
+			}
+			when, err := parseW3CTime(ts)
+			if err != nil && ts != "" {
+				log.Printf("Time parse error for %q: atom 0.3 gives %v\n", i.Title, err)
+			}
+			entries = append(entries, Entry{
+				FeedName: feed.atom03.Title,
+				FeedURL:  alternate(feed.atom03.Links),
 				Title:    i.Title,
-				URL:      i.Link.URL,
+				URL:      alternate(i.Links),
+				Author:   firstOf(i.Author, feed.atom03.Author),
+				Summary:  excerpt(firstOf(i.Summary, i.Content), summaryLen),
 				When:     when,
+				GUID:     i.ID,
+			})
+		}
+	} else if feed.rdf != nil {
+		for _, i := range feed.rdf.Items {
+			when, err := parseW3CTime(i.When)
+			if err != nil && i.When != "" {
+				log.Printf("Time parse error for %q: rdf gives %v\n", i.Title, err)
+			}
+			entries = append(entries, Entry{
+				FeedName:   feed.rdf.Channel.Title,
+				FeedURL:    feed.rdf.Channel.Link,
+				Title:      i.Title,
+				URL:        i.Link,
+				Author:     i.Creator,
+				Summary:    excerpt(firstOf(i.Description, i.Content), summaryLen),
+				When:       when,
+				Categories: i.Subjects,
+				GUID:       i.About,
 			})
 		}
 	} else {
+		mastodon := isMastodon(feed.rss.Channel.Generator)
 		for _, i := range feed.rss.Channel.Items {
-			when, err := parseRssTimes(i.When)
-			if err != nil {
+			var encs []Enclosure
+			for _, e := range i.Enclosures {
+				encs = append(encs, Enclosure{e.URL, e.Type, e.Length})
+			}
+			if mastodon {
+				encs = append(encs, i.MediaRSS.enclosures()...)
+			}
+			var when time.Time
+			var err error
+			if i.When == "" && i.DCDate != "" {
+				when, err = parseW3CTime(i.DCDate)
+			} else {
+				when, err = parseRssTimes(i.When)
+			}
+			if err != nil && i.When+i.DCDate != "" {
 				log.Printf("Time parse error for %q: rss gives %v\n", i.Title, err)
 			}
 			entries = append(entries, Entry{
-				FeedName: feed.rss.Channel.Title,
-				FeedURL:  feed.rss.Channel.Link,
-				Title:    i.Title,
-				URL:      i.Link,
-				When:     when,
+				FeedName:   feed.rss.Channel.Title,
+				FeedURL:    feed.rss.Channel.Link,
+				Title:      i.Title,
+				URL:        i.Link,
+				Author:     firstOf(i.Creator, rssAuthor(i.Author)),
+				Summary:    excerpt(firstOf(i.Description, i.Content, i.Itunes.Summary), summaryLen),
+				When:       when,
+				Enclosures: encs,
+				Comments:   i.Comments,
+				Categories: i.Categories,
+				GUID:       i.GUID,
+				Duration:   parseItunesDuration(i.Itunes.Duration),
+				Episode:    i.Itunes.Episode,
+				Artwork:    firstOf(i.Itunes.Image.URL, feed.rss.Channel.Artwork.URL),
+				Thumbnail:  i.MediaRSS.thumbnail(),
 			})
 		}
 	}
-	return entries, nil
+	return entries, feed.meta(), nil
+}
+
+// isJSON peeks past any leading whitespace to see if the body is a JSON object.
+func isJSON(br *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, err := br.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b[n-1] == '{'
+	}
+}
+
+// pickDate chooses between an item's published and updated dates,
+// according to the -date flag.
+func pickDate(published, updated string) string {
+	if *dateBy == "published" {
+		return firstOf(published, updated)
+	}
+	return firstOf(updated, published)
 }
 
-func parseRssTimes(ts string) (time.Time, error) {
-	fmts := []string{time.RFC822, time.RFC822Z, time.RFC1123, time.RFC1123Z}
+// parseW3CTime parses the W3C profile of ISO 8601 used by Atom 0.3,
+// which allows leaving off the seconds or the time entirely.
+func parseW3CTime(ts string) (time.Time, error) {
+	fmts := []string{time.RFC3339, "2006-01-02T15:04Z07:00", time.DateOnly}
 	var t time.Time
 	var err error
 	for _, f := range fmts {
@@ -284,45 +869,252 @@ func parseRssTimes(ts string) (time.Time, error) {
 	return t, err
 }
 
+// rssAuthor pulls the name out of an RSS author, which is supposed
+// to be an email address optionally followed by a name in parentheses.
+func rssAuthor(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '('); i >= 0 && strings.HasSuffix(s, ")") {
+		if name := strings.TrimSpace(s[i+1 : len(s)-1]); name != "" {
+			return name
+		}
+	}
+	return s
+}
+
 type Atom1 struct {
-	Title string `xml:"title"`
-	Link  struct {
-		URL string `xml:"href,attr"`
-	} `xml:"link"`
+	Base   string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title  string     `xml:"title"`
+	Links  []AtomLink `xml:"link"`
+	Author string     `xml:"author>name"`
 
 	Items []struct {
-		Title string `xml:"title"`
-		Link  struct {
-			URL string `xml:"href,attr"`
-		} `xml:"link"`
-		When string `xml:"updated"`
+		Base       string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+		Title      string     `xml:"title"`
+		Links      []AtomLink `xml:"link"`
+		Author     string     `xml:"author>name"`
+		ID         string     `xml:"id"`
+		Updated    string     `xml:"updated"`
+		Published  string     `xml:"published"`
+		Categories []struct {
+			Term  string `xml:"term,attr"`
+			Label string `xml:"label,attr"`
+		} `xml:"category"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+		YouTube
+		MediaRSS
 	} `xml:"entry"`
 }
 
+// An AtomLink is a <link> of an Atom feed or entry, which may be to
+// a web page, the feed itself, comments, or attached media, by its rel.
+type AtomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	URL    string `xml:"href,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+// alternate picks the link that's most likely a web page for people to read.
+// That's an HTML alternate if there is one, then any alternate, then anything
+// but the feed itself or attached media.
+func alternate(links []AtomLink) string {
+	isAlt := func(l AtomLink) bool {
+		return l.Rel == "" || l.Rel == "alternate"
+	}
+	for _, l := range links {
+		mt, _, _ := mime.ParseMediaType(l.Type)
+		if isAlt(l) && (mt == "text/html" || mt == "application/xhtml+xml") {
+			return l.URL
+		}
+	}
+	for _, l := range links {
+		if isAlt(l) && !strings.Contains(l.Type, "xml") && !strings.Contains(l.Type, "json") {
+			return l.URL
+		}
+	}
+	for _, l := range links {
+		if isAlt(l) {
+			return l.URL
+		}
+	}
+	for _, l := range links {
+		switch l.Rel {
+		case "self", "enclosure", "replies", "hub", "next", "previous", "first", "last":
+			continue
+		}
+		return l.URL
+	}
+	return ""
+}
+
+// replies picks the link to an entry's comments, preferring a web page
+// over a feed of them.
+func replies(links []AtomLink) string {
+	var feed string
+	for _, l := range links {
+		if l.Rel != "replies" {
+			continue
+		}
+		mt, _, _ := mime.ParseMediaType(l.Type)
+		if mt == "" || mt == "text/html" || mt == "application/xhtml+xml" {
+			return l.URL
+		}
+		if feed == "" {
+			feed = l.URL
+		}
+	}
+	return feed
+}
+
+// atomEnclosures is the media attached to an entry by its links,
+// resolved against base.
+func atomEnclosures(base *url.URL, links []AtomLink) []Enclosure {
+	var encs []Enclosure
+	for _, l := range links {
+		if l.Rel == "enclosure" {
+			encs = append(encs, Enclosure{resolve(base, l.URL), l.Type, l.Length})
+		}
+	}
+	return encs
+}
+
+// Atom03 is the pre-standard Atom 0.3, which has <issued>, <modified>,
+// and <created> instead of <updated>.
+type Atom03 struct {
+	Title  string     `xml:"http://purl.org/atom/ns# title"`
+	Links  []AtomLink `xml:"http://purl.org/atom/ns# link"`
+	Author string     `xml:"http://purl.org/atom/ns# author>name"`
+
+	Items []struct {
+		Title    string     `xml:"http://purl.org/atom/ns# title"`
+		Links    []AtomLink `xml:"http://purl.org/atom/ns# link"`
+		Author   string     `xml:"http://purl.org/atom/ns# author>name"`
+		ID       string     `xml:"http://purl.org/atom/ns# id"`
+		Issued   string     `xml:"http://purl.org/atom/ns# issued"`
+		Modified string     `xml:"http://purl.org/atom/ns# modified"`
+		Created  string     `xml:"http://purl.org/atom/ns# created"`
+		Summary  string     `xml:"http://purl.org/atom/ns# summary"`
+		Content  string     `xml:"http://purl.org/atom/ns# content"`
+	} `xml:"http://purl.org/atom/ns# entry"`
+}
+
 type Rss2 struct {
 	Channel struct {
 		Title string `xml:"title"`
-		Link  string `xml:"link"`
+		// AtomLinks must come before Link, which would otherwise match them.
+		AtomLinks []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link      string     `xml:"link"`
+		Artwork   struct {
+			URL string `xml:"href,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		TTL       int    `xml:"ttl"`
+		Generator string `xml:"generator"`
+		Syndication
 
 		Items []struct {
-			Title string `xml:"title"`
-			Link  string `xml:"link"`
-			When  string `xml:"pubDate"`
+			Title       string   `xml:"title"`
+			Link        string   `xml:"link"`
+			When        string   `xml:"pubDate"`
+			DCDate      string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+			Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			Author      string   `xml:"author"`
+			GUID        string   `xml:"guid"`
+			Comments    string   `xml:"comments"`
+			Categories  []string `xml:"category"`
+			Description string   `xml:"description"`
+			Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			Itunes
+			MediaRSS
+			Enclosures []struct {
+				URL    string `xml:"url,attr"`
+				Type   string `xml:"type,attr"`
+				Length int64  `xml:"length,attr"`
+			} `xml:"enclosure"`
 		} `xml:"item"`
 	} `xml:"channel"`
 }
 
+// Rss1 is RSS 1.0, which is RDF with the items alongside the channel
+// rather than inside it. Item dates come from Dublin Core.
+type Rss1 struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Link  string `xml:"link"`
+		Syndication
+	} `xml:"channel"`
+
+	Items []struct {
+		About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+		Title       string   `xml:"title"`
+		Link        string   `xml:"link"`
+		When        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+		Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+		Description string   `xml:"description"`
+		Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	} `xml:"item"`
+}
+
 type ListingPage struct {
 	Feeds []Entry
 	Begin time.Time
 }
 
 type Entry struct {
-	FeedName string
-	FeedURL  string
-	Title    string
-	URL      string
-	When     time.Time
+	FeedName   string
+	FeedURL    string
+	Title      string
+	URL        string
+	Author     string
+	Summary    string
+	When       time.Time
+	Enclosures []Enclosure
+	Categories []string
+	GUID       string
+	Thumbnail  string
+	Comments   string
+
+	// Link aggregators, like Reddit, also have these.
+	Score        int
+	CommentCount int
+
+	// Source is the URL the entry's feed was fetched from.
+	Source string
+
+	// Read is whether I've read it, or at least clicked on it.
+	Read bool
+	// Starred entries are kept until they're unstarred.
+	Starred bool
+
+	// Content is the text of the entry's article, with -extract,
+	// in paragraphs separated by blank lines.
+	Content string
+
+	// Also holds the same story from other feeds, when they're collapsed.
+	Also []Entry
+
+	// Podcast episodes may also have these.
+	Duration time.Duration
+	Episode  int
+	Artwork  string
+}
+
+// Enclosure is media attached to an entry, like a podcast episode.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+// Kind describes the enclosure's media for display.
+func (e Enclosure) Kind() string {
+	kind, _, _ := strings.Cut(e.Type, "/")
+	switch kind {
+	case "audio", "video", "image":
+		return kind
+	}
+	return "media"
 }
 
 func filterEntries(feeds []Entry, begin, end time.Time) []Entry {
@@ -338,37 +1130,138 @@ func filterEntries(feeds []Entry, begin, end time.Time) []Entry {
 	return filtered
 }
 
-var dailyPage = template.Must(template.New("daily").Parse(dailyPageTemplate))
+// key identifies an entry across fetches: by its GUID if its feed gives one,
+// otherwise by its URL.
+func (e Entry) key() string {
+	return e.FeedURL + "\x00" + firstOf(e.GUID, e.URL)
+}
+
+// linkKey identifies an entry without a GUID of its own by its feed and
+// its canonical URL, or is empty if it has a GUID or no URL. A GUID that's
+// just the URL doesn't count, since that's what stores key it by.
+// Entries with GUIDs are told apart by them, even if they share a link,
+// like podcast episodes that all link to the show's page.
+func (e Entry) linkKey() string {
+	if e.URL == "" || (e.GUID != "" && e.GUID != e.URL) {
+		return ""
+	}
+	return e.FeedURL + "\x00" + canonicalURL(e.URL)
+}
+
+// dedupe drops entries with the same key or link key as an earlier one,
+// so items without GUIDs that feeds re-emit with a trivially different URL,
+// like another scheme or tracking parameters, aren't duplicated.
+// If prev has an entry with either key, its date is kept, so items
+// whose feeds regenerate their dates don't hop from day to day,
+// and so is whether it's been read or starred, and its article.
+// Undated items are dated when they're first seen.
+func dedupe(entries, prev []Entry) []Entry {
+	now := time.Now().UTC()
+	seen := map[string]Entry{}
+	for _, e := range prev {
+		seen[e.key()] = e
+		if lk := e.linkKey(); lk != "" {
+			seen[lk] = e
+		}
+	}
+
+	kept := map[string]bool{}
+	var deduped []Entry
+	for _, e := range entries {
+		k, lk := e.key(), e.linkKey()
+		if kept[k] || (lk != "" && kept[lk]) {
+			continue
+		}
+		kept[k] = true
+		if lk != "" {
+			kept[lk] = true
+		}
+		p, ok := seen[k]
+		if !ok && lk != "" {
+			p, ok = seen[lk]
+		}
+		if ok && !p.When.IsZero() {
+			e.When = p.When
+		} else if e.When.IsZero() {
+			e.When = now
+		}
+		e.Read = e.Read || p.Read
+		e.Starred = e.Starred || p.Starred
+		e.Content = firstOf(e.Content, p.Content)
+		deduped = append(deduped, e)
+	}
+	return deduped
+}
+
+var dailyPage = newPage("daily", dailyPageTemplate)
 
 type Daily struct {
-	Sites []Site
+	Day  time.Time
+	Base string
+	Link string
+	Paging
+	Sites   []Site
 	Singles []Entry
 }
 
 type Site struct {
-	Name string
+	Name    string
 	Entries []Entry
 }
 
+// URL is the site's home page.
+func (s Site) URL() string {
+	return s.Entries[0].FeedURL
+}
+
+// Source is the URL of the site's feed.
+func (s Site) Source() string {
+	return s.Entries[0].Source
+}
+
+// ID identifies the site's feed in links, like feed/ID.
+func (s Site) ID() string {
+	return feedID(s.Source())
+}
+
 var dailyPageTemplate = `<!DOCTYPE html>
 <html>
 <head>
 	<meta charset="utf-8">
 	<meta name="viewport" content="width=device-width, initial-scale=1">
 
-	<link rel="icon" href="style/favicon.png">
+{{with .Base}}	<base href="{{.}}">
+{{end}}	<link rel="icon" href="style/favicon.png">
 	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+	<link rel="manifest" href="style/manifest.json">
+	<link rel="alternate" type="application/atom+xml" title="WEBRSS" href="feed.atom">
+	<meta name="theme-color" content="rgb(255,255,240)">
+	<script>
+		if ('serviceWorker' in navigator) {
+			navigator.serviceWorker.register('sw.js');
+		}
+	</script>
 
 	<title>WEBRSS Today</title>
 </head>
 
 <body>
-{{if .Singles}}
+		<nav class="details"><a href="day?date={{.Prev}}">←</a> <a href="month?m={{.Month}}">{{.Day.Format "Monday, 2 January"}}</a>{{with .Next}} <a href="day?date={{.}}">→</a>{{end}} · <a href="week">week</a> · <a href="unread">unread</a> · <a href="starred">starred</a> · <a href="search">search</a> · <form class="mark" method="post" action="theme">theme <button class="details" name="theme" value="light">light</button> <button class="details" name="theme" value="dark">dark</button> <button class="details" name="theme" value="">system</button></form></nav>
+{{template "failed"}}{{range .Groups}}{{if .Name}}
+		<details class="group" open>
+			<summary>{{.Name}}</summary>
+{{template "cards" .Daily}}
+		</details>
+{{else}}{{template "cards" .Daily}}{{end}}{{end}}
+{{template "pages" .}}{{template "muted"}}</body>
+</html>
+{{define "cards"}}{{if .Singles}}
 		<div class="card">
-			<h1>★ Singles ★</h1>
+			<h1>★ Singles ★{{template "mark" .Singles}}</h1>
 			<ul>
 {{range .Singles}}
-				<li class="card-item"><a href="{{.URL}}">{{.Title}}</a><span class="details"> (<a href="{{.FeedURL}}">{{.FeedName}}</a>)</span></li>
+				<li class="card-item{{if .Read}} read{{end}}">{{template "media" .}}<span class="details">{{with .Author}} by {{.}}{{end}} (<a href="{{.FeedURL}}">{{with icon .FeedURL}}<img class="icon" src="{{.}}" alt="">{{end}}{{.FeedName}}</a>){{if $.Day.IsZero}} {{.When.Format "2 Jan 2006"}}{{end}}</span>{{template "also" .}}{{template "star" .}}{{template "markone" .}}{{template "summary" .}}{{template "thumbnail" .}}</li>
 {{end}}
 			</ul>
 		</div>
@@ -377,16 +1270,34 @@ var dailyPageTemplate = `<!DOCTYPE html>
 	<ul>
 {{range .Sites}}
 		<li class="card">
-			<h1>{{.Name}}</h1>
+			<h1>{{with icon .URL}}<img class="icon" src="{{.}}" alt="">{{end}}<a href="feed/{{.ID}}">{{.Name}}</a>{{template "mark" .Entries}}{{template "mute" .}}</h1>
 			<ul>
 {{range .Entries}}
-				<li class="card-item"><a href="{{.URL}}">{{.Title}}</a></li>
+				<li class="card-item{{if .Read}} read{{end}}">{{template "media" .}}<span class="details">{{with .Author}} by {{.}}{{end}}{{if $.Day.IsZero}} {{.When.Format "2 Jan 2006"}}{{end}}</span>{{template "also" .}}{{template "star" .}}{{template "markone" .}}{{template "summary" .}}{{template "thumbnail" .}}</li>
 {{end}}
 			</ul>
 		</li>
 {{end}}
 	</ul>
-{{end}}
-</body>
-</html>
+{{end}}{{end}}
+{{define "pages"}}{{if gt .Pages 1}}		<nav class="details">{{with .PrevPage}}<a href="{{$.Link}}?page={{.}}">←</a> {{end}}page {{.Page}} of {{.Pages}}{{with .NextPage}} <a href="{{$.Link}}?page={{.}}">→</a>{{end}}</nav>
+{{end}}{{end}}
+{{define "summary"}}{{if .Summary}}{{if eq .Teaser .Summary}}<p class="summary">{{.Summary}}</p>{{else}}<details class="summary"><summary><span class="teaser">{{.Teaser}}</span></summary>{{.Summary}}</details>{{end}}{{end}}{{end}}
+{{define "also"}}{{with .Also}}<span class="details"> also via {{range $i, $e := .}}{{if $i}}, {{end}}<a href="{{.URL}}">{{.FeedName}}</a>{{end}}</span>{{end}}{{end}}
+{{define "thumbnail"}}{{with .Thumbnail}}<a href="{{$.URL}}"><img class="thumbnail" src="{{.}}" alt=""></a>{{end}}{{end}}
+{{define "star"}}<form class="mark" method="post" action="star"><input type="hidden" name="entry" value="{{.ID}}"><input type="hidden" name="star" value="{{not .Starred}}"><button class="details" title="{{if .Starred}}Unstar{{else}}Star{{end}}">{{if .Starred}}★{{else}}☆{{end}}</button></form>{{end}}
+{{define "markone"}}{{if not .Read}}<form class="mark" method="post" action="read"><input type="hidden" name="entry" value="{{.ID}}"><button class="details" title="Mark read">✓</button></form>{{end}}{{end}}
+{{define "mute"}}<form class="mark" method="post" action="mute"><input type="hidden" name="feed" value="{{.ID}}"><input type="hidden" name="name" value="{{.Name}}"><select class="details" name="for"><option value="24h">for a day</option><option value="7d">for a week</option><option value="">until unmuted</option></select> <button class="details">mute</button></form>{{end}}
+{{define "failed"}}{{with failed}}		<details class="details failed">
+			<summary>{{len .}} feed{{if gt (len .) 1}}s{{end}} failed to update</summary>
+			<ul>
+{{range .}}				<li>{{.}}</li>
+{{end}}			</ul>
+			<a href="status">How every feed is doing</a>
+		</details>
+{{end}}{{end}}
+{{define "muted"}}{{with muted}}		<nav class="details">Muted: {{range $i, $m := .}}{{if $i}}, {{end}}{{.Name}}{{if not .Until.IsZero}} until {{.Until.Format "2 Jan 15:04"}}{{end}}<form class="mark" method="post" action="mute"><input type="hidden" name="feed" value="{{.ID}}"><button class="details" name="unmute" value="true">unmute</button></form>{{end}}</nav>
+{{end}}{{end}}
+{{define "mark"}}<form class="mark" method="post" action="read">{{range .}}<input type="hidden" name="entry" value="{{.ID}}">{{end}}<button class="details">mark read</button></form>{{end}}
+{{define "media"}}{{with .Artwork}}<img class="artwork" src="{{.}}" alt="">{{end}}<a href="{{.URL}}" ping="read?entry={{.ID}}">{{.Title}}</a>{{if .Content}} <a class="details" href="article?id={{.ID}}">[article]</a>{{end}}{{range .Enclosures}} <a class="details" href="{{.URL}}">[{{.Kind}}]</a>{{end}}{{with .Comments}} <a class="details" href="{{.}}">[{{with $.CommentCount}}{{.}} {{end}}comments]</a>{{end}}{{with .Score}}<span class="details"> {{.}} points</span>{{end}}{{if or .Episode .Duration}}<span class="details">{{with .Episode}} ep. {{.}}{{end}}{{with .Duration}} {{$.Length}}{{end}}</span>{{end}}{{end}}
 `
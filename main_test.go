@@ -0,0 +1,81 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDedupe(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	episode := func(guid string) Entry {
+		return Entry{FeedURL: "https://show.example/", Title: guid, URL: "https://show.example/", GUID: guid, When: day}
+	}
+	post := func(title, u string) Entry {
+		return Entry{FeedURL: "https://blog.example/", Title: title, URL: u, When: day}
+	}
+
+	tests := []struct {
+		name          string
+		entries, prev []Entry
+		want          []string
+	}{
+		{
+			name:    "distinct GUIDs sharing a link",
+			entries: []Entry{episode("ep-1"), episode("ep-2"), episode("ep-3")},
+			want:    []string{"ep-1", "ep-2", "ep-3"},
+		},
+		{
+			name:    "distinct GUIDs sharing a link with an earlier copy",
+			entries: []Entry{episode("ep-1"), episode("ep-2"), episode("ep-3")},
+			prev:    []Entry{episode("ep-1")},
+			want:    []string{"ep-1", "ep-2", "ep-3"},
+		},
+		{
+			name:    "repeated GUID",
+			entries: []Entry{episode("ep-1"), episode("ep-1")},
+			want:    []string{"ep-1"},
+		},
+		{
+			name: "no GUIDs, trivially different links",
+			entries: []Entry{
+				post("first", "https://blog.example/post/"),
+				post("second", "http://www.blog.example/post?utm_source=rss"),
+			},
+			want: []string{"first"},
+		},
+		{
+			name:    "no GUIDs, different links",
+			entries: []Entry{post("first", "https://blog.example/a"), post("second", "https://blog.example/b")},
+			want:    []string{"first", "second"},
+		},
+		{
+			name:    "a GUID doesn't match a link without one",
+			entries: []Entry{post("no GUID", "https://show.example/"), episode("ep-1")},
+			want:    []string{"no GUID", "ep-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range dedupe(tt.entries, tt.prev) {
+				got = append(got, e.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("dedupe = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupeKeepsEarlierCopy(t *testing.T) {
+	first := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	prev := []Entry{{FeedURL: "https://blog.example/", URL: "https://blog.example/post", When: first, Read: true}}
+	next := []Entry{{FeedURL: "https://blog.example/", URL: "http://blog.example/post/", When: first.AddDate(0, 0, 1)}}
+	got := dedupe(next, prev)
+	if len(got) != 1 || !got[0].When.Equal(first) || !got[0].Read {
+		t.Errorf("dedupe = %+v, want the entry dated %v and read", got, first)
+	}
+}
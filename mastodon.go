@@ -0,0 +1,29 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import "strings"
+
+// isMastodon reports whether a feed's generator is Mastodon, whose posts
+// have no titles, and whose attachments are only given as Media RSS.
+func isMastodon(generator string) bool {
+	return strings.HasPrefix(strings.TrimSpace(generator), "Mastodon")
+}
+
+// enclosures are the media attached to the item.
+func (m MediaRSS) enclosures() []Enclosure {
+	var encs []Enclosure
+	for _, s := range []mediaSet{m.mediaSet, m.Group.mediaSet} {
+		for _, c := range s.Contents {
+			if c.URL == "" {
+				continue
+			}
+			t := c.Type
+			if t == "" && c.Medium != "" {
+				t = c.Medium + "/*"
+			}
+			encs = append(encs, Enclosure{c.URL, t, c.Length})
+		}
+	}
+	return encs
+}
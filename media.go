@@ -0,0 +1,149 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var ogImages = flag.Bool("og-image", false, "Fetch recent articles without thumbnails for their og:image")
+
+// MediaRSS holds the images of an item from the Media RSS namespace.
+// See https://www.rssboard.org/media-rss.
+type MediaRSS struct {
+	mediaSet
+	Group struct {
+		mediaSet
+		Description string `xml:"http://search.yahoo.com/mrss/ description"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+type mediaSet struct {
+	Thumbnails []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Contents []struct {
+		URL        string `xml:"url,attr"`
+		Type       string `xml:"type,attr"`
+		Medium     string `xml:"medium,attr"`
+		Length     int64  `xml:"fileSize,attr"`
+		Thumbnails []struct {
+			URL string `xml:"url,attr"`
+		} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// thumbnail picks the best image for the item: a thumbnail if there is one,
+// otherwise an image it includes.
+func (m MediaRSS) thumbnail() string {
+	return firstOf(m.mediaSet.thumbnail(), m.Group.mediaSet.thumbnail())
+}
+
+func (s mediaSet) thumbnail() string {
+	for _, t := range s.Thumbnails {
+		if t.URL != "" {
+			return t.URL
+		}
+	}
+	for _, c := range s.Contents {
+		for _, t := range c.Thumbnails {
+			if t.URL != "" {
+				return t.URL
+			}
+		}
+	}
+	for _, c := range s.Contents {
+		mt, _, _ := mime.ParseMediaType(c.Type)
+		if c.Medium == "image" || strings.HasPrefix(mt, "image/") {
+			return c.URL
+		}
+	}
+	return ""
+}
+
+// ogWindow is how recent an entry must be to look for its og:image.
+const ogWindow = 48 * time.Hour
+
+// ogSeen remembers the og:image of each article that's been looked at,
+// even if it had none, so each is fetched at most once.
+var ogSeen = struct {
+	sync.Mutex
+	images map[string]string
+}{
+	images: map[string]string{},
+}
+
+// addOGImages gives recent entries without a thumbnail their article's
+// og:image, if the -og-image flag is set.
+func addOGImages(entries []Entry) {
+	if !*ogImages {
+		return
+	}
+	for i := range entries {
+		e := &entries[i]
+		if e.Thumbnail != "" || e.URL == "" || time.Since(e.When) > ogWindow {
+			continue
+		}
+
+		ogSeen.Lock()
+		img, ok := ogSeen.images[e.URL]
+		ogSeen.Unlock()
+		if !ok {
+			var err error
+			img, err = findOGImage(e.URL)
+			if err != nil {
+				log.Printf("No og:image for %s: %v\n", e.URL, err)
+			}
+			ogSeen.Lock()
+			ogSeen.images[e.URL] = img
+			ogSeen.Unlock()
+		}
+		e.Thumbnail = img
+	}
+}
+
+// findOGImage looks in the article's <head> for <meta property="og:image">.
+func findOGImage(article string) (string, error) {
+	resp, err := newClient().Get(article)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if !isHTML(resp.Header.Get("Content-Type")) {
+		return "", nil
+	}
+
+	z := html.NewTokenizer(resp.Body)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return "", nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "body" {
+				return "", nil
+			}
+			if t.Data != "meta" {
+				continue
+			}
+			prop := firstOf(attr(t, "property"), attr(t, "name"))
+			if prop != "og:image" && prop != "og:image:url" && prop != "twitter:image" {
+				continue
+			}
+			if c := attr(t, "content"); c != "" {
+				u, err := resp.Request.URL.Parse(c)
+				if err != nil {
+					return "", err
+				}
+				return u.String(), nil
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A Mute hides a feed's entries from the pages of days, weeks, months,
+// and unread entries, without unsubscribing from it.
+type Mute struct {
+	// Source is the URL of the feed.
+	Source string
+	// Name is the feed's name, for listing it.
+	Name string
+	// Until is when the feed is shown again, or zero if it's muted
+	// until it's unmuted.
+	Until time.Time
+}
+
+// ID identifies the muted feed in forms, like feed/ID.
+func (m Mute) ID() string {
+	return feedID(m.Source)
+}
+
+// over reports whether the mute has run out.
+func (m Mute) over() bool {
+	return !m.Until.IsZero() && !m.Until.After(time.Now())
+}
+
+// mutes holds the muted feeds, by the URL of the feed.
+var mutes = struct {
+	sync.Mutex
+	byFeed map[string]Mute
+}{
+	byFeed: map[string]Mute{},
+}
+
+// muteFile is where mutes are kept, next to the feed cache.
+func muteFile() string {
+	return filepath.Join(filepath.Dir(*cache), "mutes.gob")
+}
+
+// loadMutes reads the mutes saved by the last run.
+func loadMutes() {
+	b, err := readFile(muteFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		log.Printf("Couldn't read %s: %v\n", muteFile(), err)
+		return
+	}
+	mutes.Lock()
+	defer mutes.Unlock()
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&mutes.byFeed)
+	if err != nil {
+		log.Printf("Couldn't read %s: %v\n", muteFile(), err)
+	}
+}
+
+// saveMutes writes the mutes that haven't run out. The mutes must be locked.
+func saveMutes() {
+	for u, m := range mutes.byFeed {
+		if m.over() {
+			delete(mutes.byFeed, u)
+		}
+	}
+	err := replaceFile(muteFile(), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(mutes.byFeed)
+	})
+	if err != nil {
+		log.Printf("Couldn't save mutes: %v\n", err)
+	}
+}
+
+// mutedFeeds is the feeds that are muted, by name.
+func mutedFeeds() []Mute {
+	mutes.Lock()
+	defer mutes.Unlock()
+	var muted []Mute
+	for _, m := range mutes.byFeed {
+		if !m.over() {
+			muted = append(muted, m)
+		}
+	}
+	slices.SortFunc(muted, func(a, b Mute) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return muted
+}
+
+// unmuted is the entries that aren't from muted feeds.
+func unmuted(entries []Entry) []Entry {
+	mutes.Lock()
+	defer mutes.Unlock()
+	if len(mutes.byFeed) == 0 {
+		return entries
+	}
+	var shown []Entry
+	for _, e := range entries {
+		if m, ok := mutes.byFeed[e.Source]; !ok || m.over() {
+			shown = append(shown, e)
+		}
+	}
+	return shown
+}
+
+// mutable is the feed with the ID, of those subscribed to or muted,
+// or empty if there's none.
+func mutable(id string) string {
+	for _, u := range subscribed() {
+		if feedID(u) == id {
+			return u
+		}
+	}
+	mutes.Lock()
+	defer mutes.Unlock()
+	for u := range mutes.byFeed {
+		if feedID(u) == id {
+			return u
+		}
+	}
+	return ""
+}
+
+// muteHandler mutes the feed posted to it by its ID, for as long as is posted,
+// like 24h or 7d, or until it's unmuted. It unmutes it if unmute is posted.
+// It's sent back to the page it came from. Other sites can't post to it.
+func muteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Muting takes a POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	if crossSite(r) {
+		http.Error(w, "Feeds can't be muted from other sites.", http.StatusForbidden)
+		return
+	}
+	id := r.FormValue("feed")
+	if id == "" {
+		http.Error(w, "I need the feed to mute.", http.StatusBadRequest)
+		return
+	}
+	m := Mute{Source: mutable(id), Name: r.FormValue("name")}
+	if m.Source == "" {
+		http.Error(w, "I'm not subscribed to that feed.", http.StatusNotFound)
+		return
+	}
+	if d := r.FormValue("for"); d != "" {
+		dur, err := parseInterval(d)
+		if err != nil {
+			http.Error(w, "How long to mute must be like 24h or 7d.", http.StatusBadRequest)
+			return
+		}
+		m.Until = time.Now().In(zone).Add(dur)
+	}
+
+	mutes.Lock()
+	if r.FormValue("unmute") != "" {
+		delete(mutes.byFeed, m.Source)
+	} else {
+		mutes.byFeed[m.Source] = m
+	}
+	saveMutes()
+	mutes.Unlock()
+
+	back := r.Referer()
+	if back == "" {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,93 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strconv"
+)
+
+var pageSize = flag.Int("page-size", 100, "Most entries on a page of a day, or 0 to show them all at once")
+
+// A dayView is how a day's page was asked for.
+type dayView struct {
+	// base is where the page's links are relative to, if it's not
+	// where the page is, like for day/2021-06-01.
+	base string
+	// link is the page, relative to base, for linking to its other pages.
+	// It's empty for pages that are where they're linked from.
+	link string
+	// page is which page of the day's entries to show, from 1.
+	page int
+}
+
+// pageOf is the page asked for as page, or the first.
+func pageOf(r *http.Request) int {
+	n, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Paging is which page of entries is shown, from 1, of how many.
+type Paging struct {
+	Page, Pages int
+}
+
+// paging is the given page of n entries, and where its entries start
+// and end among them.
+func paging(n, page int) (p Paging, start, end int) {
+	if *pageSize <= 0 || n <= *pageSize {
+		return Paging{1, 1}, 0, n
+	}
+	p.Pages = (n + *pageSize - 1) / *pageSize
+	p.Page = min(page, p.Pages)
+	return p, (p.Page - 1) * *pageSize, min(p.Page**pageSize, n)
+}
+
+// PrevPage is the page before the one shown, or 0 if it's the first.
+func (p Paging) PrevPage() int {
+	return p.Page - 1
+}
+
+// NextPage is the page after the one shown, or 0 if it's the last.
+func (p Paging) NextPage() int {
+	if p.Page >= p.Pages {
+		return 0
+	}
+	return p.Page + 1
+}
+
+// paginate keeps the given page of -page-size entries of d, counting
+// the singles first, then each site's in turn. A site's entries may
+// be split across pages.
+func (d *Daily) paginate(page int) {
+	n := len(d.Singles)
+	for _, s := range d.Sites {
+		n += len(s.Entries)
+	}
+	var start, end int
+	d.Paging, start, end = paging(n, page)
+
+	// pick is those of entries, which start at the at'th, that are on the page.
+	pick := func(entries []Entry, at int) []Entry {
+		lo, hi := max(start-at, 0), min(end-at, len(entries))
+		if lo >= hi {
+			return nil
+		}
+		return entries[lo:hi]
+	}
+	at := 0
+	singles := pick(d.Singles, at)
+	at += len(d.Singles)
+	var sites []Site
+	for _, s := range d.Sites {
+		if entries := pick(s.Entries, at); entries != nil {
+			sites = append(sites, Site{s.Name, entries})
+		}
+		at += len(s.Entries)
+	}
+	d.Singles, d.Sites = singles, sites
+}
@@ -0,0 +1,44 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Itunes holds the podcast extensions of an RSS item.
+// See https://help.apple.com/itc/podcasts_connect/#/itcb54353390.
+type Itunes struct {
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Episode  int    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	Summary  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	Image    struct {
+		URL string `xml:"href,attr"`
+	} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+}
+
+// parseItunesDuration reads an itunes:duration, which is either
+// a number of seconds or [[HH:]MM:]SS.
+func parseItunesDuration(s string) time.Duration {
+	var d time.Duration
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second
+}
+
+// Length is the entry's duration, rounded to the minute for display.
+func (e Entry) Length() string {
+	m := int(e.Duration.Round(time.Minute) / time.Minute)
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
@@ -0,0 +1,25 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+)
+
+// serviceWorker serves sw.js from the style files. It's served from
+// the top, rather than with them, so it can keep copies of every page
+// for reading offline.
+func serviceWorker(w http.ResponseWriter, r *http.Request) {
+	b, err := fs.ReadFile(styleFiles(), "sw.js")
+	if err != nil {
+		log.Printf("Couldn't read the service worker: %v\n", err)
+		http.Error(w, "The service worker is missing.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	// Browsers check for a new one with every visit, but only if it isn't cached.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(b)
+}
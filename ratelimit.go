@@ -0,0 +1,60 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var hostDelay = flag.Duration("host-delay", time.Second, "Shortest duration between requests to the same host")
+
+// hostTurns holds when each host may next be sent a request.
+var hostTurns = struct {
+	sync.Mutex
+	next map[string]time.Time
+}{
+	next: map[string]time.Time{},
+}
+
+// hostLimiter is a RoundTripper that spaces out requests to the same host
+// by -host-delay, so many feeds on one site don't get us throttled.
+// The wait counts against the -timeout. The Hacker News API isn't limited,
+// since it's sent a request for every story on a list, and hnFetchers
+// already bounds how many are sent at once.
+type hostLimiter struct {
+	next http.RoundTripper
+}
+
+func (h hostLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	if host == hnAPIHost {
+		return h.next.RoundTrip(req)
+	}
+	wait := time.Until(takeTurn(host))
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	return h.next.RoundTrip(req)
+}
+
+// takeTurn reserves the next time a request can be sent to host.
+func takeTurn(host string) time.Time {
+	hostTurns.Lock()
+	defer hostTurns.Unlock()
+	turn := time.Now()
+	if next := hostTurns.next[host]; next.After(turn) {
+		turn = next
+	}
+	hostTurns.next[host] = turn.Add(*hostDelay)
+	return turn
+}
@@ -0,0 +1,123 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// ID identifies the entry in links and forms, where its key won't do.
+func (e Entry) ID() string {
+	h := fnv.New64a()
+	h.Write([]byte(e.key()))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// A mark changes the entries with the given IDs, like marking them read.
+type mark struct {
+	ids    []string
+	change func(*Entry)
+}
+
+// apply is entries with the marked ones changed.
+// The entries themselves are left alone, since they may be being shown.
+func (m mark) apply(entries []Entry) []Entry {
+	ids := map[string]bool{}
+	for _, id := range m.ids {
+		ids[id] = true
+	}
+	marked := slices.Clone(entries)
+	for i := range marked {
+		if ids[marked[i].ID()] {
+			m.change(&marked[i])
+		}
+	}
+	return marked
+}
+
+// markHandler sends a mark of the entries posted to it to toMark.
+// They're clicked links, which ping it, or from a form, which is sent back
+// to the page it came from. Other sites can't post to it, so they can't
+// mark entries behind the reader's back.
+func markHandler(toMark chan<- mark, change func(r *http.Request) func(*Entry)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Marking entries takes a POST.", http.StatusMethodNotAllowed)
+			return
+		}
+		if crossSite(r) {
+			http.Error(w, "Entries can't be marked from other sites.", http.StatusForbidden)
+			return
+		}
+		r.ParseForm()
+		select {
+		case toMark <- mark{r.Form["entry"], change(r)}:
+		case <-r.Context().Done():
+			return
+		}
+		if r.Header.Get("Ping-To") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		back := r.Referer()
+		if back == "" {
+			back = "/"
+		}
+		http.Redirect(w, r, back, http.StatusSeeOther)
+	})
+}
+
+// readHandler marks entries as read: one when its link is clicked,
+// or a whole card's worth from its button.
+func readHandler(toMark chan<- mark) http.Handler {
+	return markHandler(toMark, func(*http.Request) func(*Entry) {
+		return func(e *Entry) { e.Read = true }
+	})
+}
+
+// showUnread shows a page of the entries that haven't been read,
+// whenever they're from, grouped by site.
+func showUnread(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	var unread []Entry
+	for _, e := range unmuted(<-fc) {
+		if !e.Read {
+			unread = append(unread, e)
+		}
+	}
+	slices.SortFunc(unread, func(a, b Entry) int {
+		return b.When.Compare(a.When)
+	})
+	d := bySite(collapse(unread))
+	d.Link = "unread"
+	d.paginate(pageOf(r))
+	unreadPage.Execute(w, struct {
+		Daily
+		Count int
+	}{d, len(unread)})
+}
+
+var unreadPage = newPage("unread", unreadPageTemplate)
+
+var unreadPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Unread</title>
+</head>
+
+<body>
+		<nav class="details"><a href="./">today</a> · {{.Count}} unread · <a href="starred">starred</a> · <a href="search">search</a></nav>
+{{template "cards" .Daily}}
+{{template "pages" .Daily}}</body>
+</html>
+`
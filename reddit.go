@@ -0,0 +1,125 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// RedditListing is a page of posts from Reddit's JSON API,
+// like https://www.reddit.com/r/golang/.json.
+type RedditListing struct {
+	Kind string `json:"kind"`
+	Data struct {
+		Children []struct {
+			Data struct {
+				Name        string  `json:"name"`
+				Title       string  `json:"title"`
+				URL         string  `json:"url"`
+				Permalink   string  `json:"permalink"`
+				Author      string  `json:"author"`
+				SelfText    string  `json:"selftext"`
+				Created     float64 `json:"created_utc"`
+				Score       int     `json:"score"`
+				NumComments int     `json:"num_comments"`
+				Thumbnail   string  `json:"thumbnail"`
+				Flair       string  `json:"link_flair_text"`
+				Subreddit   string  `json:"subreddit_name_prefixed"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+const redditHome = "https://www.reddit.com"
+
+// isReddit reports whether u is on one of Reddit's hosts.
+func isReddit(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "reddit.com" || strings.HasSuffix(host, ".reddit.com")
+}
+
+// redditListing turns the plain URL of a subreddit or user into the URL
+// of its JSON listing. Any other URL, including Reddit's own feeds, is
+// returned as it is.
+func redditListing(u *url.URL) *url.URL {
+	if !isReddit(u) {
+		return u
+	}
+	p := strings.TrimSuffix(u.Path, "/")
+	ext := path.Ext(p)
+	if ext == ".json" || ext == ".rss" || strings.Contains(p, "/comments/") {
+		return u
+	}
+	dir, _, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	if dir != "r" && dir != "user" && dir != "u" {
+		return u
+	}
+
+	l := *u
+	l.Scheme = "https"
+	l.Host = "www.reddit.com"
+	l.Path = p + "/.json"
+	q := l.Query()
+	q.Set("raw_json", "1")
+	l.RawQuery = q.Encode()
+	return &l
+}
+
+// isRedditListing reports whether the JSON in b is a Reddit listing.
+func isRedditListing(b []byte) bool {
+	var probe struct {
+		Kind string `json:"kind"`
+	}
+	return json.Unmarshal(b, &probe) == nil && probe.Kind == "Listing"
+}
+
+// parseRedditListing makes entries of the posts in the listing fetched from base.
+// The entries link to what was posted, with the Reddit thread as their comments.
+func parseRedditListing(base *url.URL, b []byte) ([]Entry, Meta, error) {
+	var listing RedditListing
+	err := json.Unmarshal(b, &listing)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	// The name is the subreddit or user, like r/golang, even for a listing
+	// like r/golang/top.
+	segs := strings.Split(strings.Trim(base.Path, "/"), "/")
+	name := strings.Join(segs[:min(2, len(segs))], "/")
+	name = strings.TrimSuffix(strings.Replace(name, "user/", "u/", 1), ".json")
+	feedURL := redditHome + "/" + name + "/"
+
+	var entries []Entry
+	for _, c := range listing.Data.Children {
+		p := c.Data
+		thread := redditHome + p.Permalink
+		thumb := p.Thumbnail
+		if !strings.HasPrefix(thumb, "http") {
+			thumb = ""
+		}
+		var cats []string
+		if p.Flair != "" {
+			cats = []string{p.Flair}
+		}
+		entries = append(entries, Entry{
+			FeedName:     name,
+			FeedURL:      feedURL,
+			Title:        p.Title,
+			URL:          firstOf(p.URL, thread),
+			Author:       p.Author,
+			Summary:      excerpt(p.SelfText, summaryLen),
+			When:         time.Unix(int64(p.Created), 0).UTC(),
+			Categories:   cats,
+			GUID:         p.Name,
+			Thumbnail:    thumb,
+			Comments:     thread,
+			Score:        p.Score,
+			CommentCount: p.NumComments,
+		})
+	}
+	return entries, Meta{}, nil
+}
@@ -0,0 +1,136 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var rewriteFeeds = flag.Bool("rewrite-feeds", false, "Update the feeds file when a feed permanently moves")
+
+// moved maps the URLs of feeds that have permanently redirected
+// to where they are now.
+var moved = struct {
+	sync.Mutex
+	to map[string]string
+}{
+	to: map[string]string{},
+}
+
+// location is where to fetch the feed listed as u.
+func location(u string) string {
+	moved.Lock()
+	defer moved.Unlock()
+	if to, ok := moved.to[u]; ok {
+		return to
+	}
+	return u
+}
+
+// movedAccess is how to fetch the feed listed as u from where it is now.
+// Its credentials are for the host it's listed on, so they aren't sent
+// to another one it has moved to.
+func movedAccess(u string) (string, access) {
+	at, a := location(u), accessFor(u)
+	if lu, err := url.Parse(at); at != u && (err != nil || !sameSite(u, lu)) {
+		a = a.public()
+	}
+	return at, a
+}
+
+// relocate records that the feed listed as u is now at to.
+func relocate(u, to string) {
+	moved.Lock()
+	prev := moved.to[u]
+	moved.to[u] = to
+	moved.Unlock()
+	if prev == to {
+		return
+	}
+	statuses.Lock()
+	s := statuses.byFeed[u]
+	s.MovedTo = to
+	statuses.byFeed[u] = s
+	statuses.Unlock()
+
+	log.Printf("%s has moved to %s\n", u, to)
+	if *rewriteFeeds && *feeds != "" {
+		if lu, err := url.Parse(to); accessFor(u).private() && (err != nil || !sameSite(u, lu)) {
+			// Its line in the feeds file has credentials for the old host.
+			log.Printf("Not updating %s, since %s has credentials and moved to another host\n", *feeds, u)
+			return
+		}
+		err := rewriteFeedsFile(to, u, prev)
+		if err != nil {
+			log.Printf("Couldn't update %s: %v\n", *feeds, err)
+		}
+	}
+}
+
+// permanentRedirects returns a redirect policy that records in *to where
+// the request ends up, as long as every redirect along the way is permanent.
+func permanentRedirects(to *string) func(*http.Request, []*http.Request) error {
+	permanent := true
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		code := req.Response.StatusCode
+		permanent = permanent && (code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect)
+		if permanent {
+			*to = req.URL.String()
+		} else {
+			*to = ""
+		}
+		return nil
+	}
+}
+
+// rewriteFeedsFile replaces any feed listed as one of old with to in the feeds file.
+func rewriteFeedsFile(to string, old ...string) error {
+	feedsFileLock.Lock()
+	defer feedsFileLock.Unlock()
+	b, err := readFile(*feeds)
+	if err != nil {
+		return err
+	}
+	if isOPML(b) {
+		for _, o := range old {
+			if o != "" {
+				b = bytes.ReplaceAll(b, xmlURLAttr(o), xmlURLAttr(to))
+			}
+		}
+		return saveFeedsFile(b)
+	}
+	lines := strings.Split(string(b), "\n")
+	for i, l := range lines {
+		f := strings.Fields(l)
+		if len(f) == 0 {
+			continue
+		}
+		for _, o := range old {
+			if o != "" && f[0] == o {
+				lines[i] = strings.Replace(l, f[0], to, 1)
+				break
+			}
+		}
+	}
+	return saveFeedsFile([]byte(strings.Join(lines, "\n")))
+}
+
+// xmlURLAttr is the attribute of an OPML outline for the feed at u.
+func xmlURLAttr(u string) []byte {
+	var b bytes.Buffer
+	b.WriteString(`xmlUrl="`)
+	xml.EscapeText(&b, []byte(u))
+	b.WriteString(`"`)
+	return b.Bytes()
+}
@@ -0,0 +1,82 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"log"
+	"slices"
+	"time"
+)
+
+var keepFor = flag.Duration("keep", 0, "How long to keep entries, or 0 to keep them as long as their feeds do")
+var keepPerFeed = flag.Int("keep-per-feed", 0, "Most entries to keep of each feed, or 0 for no limit")
+
+// pruneEvery is how often entries beyond -keep or -keep-per-feed are dropped.
+const pruneEvery = time.Hour
+
+// pruning ticks when it's time to prune, or never if there's no limit.
+func pruning() <-chan time.Time {
+	if *keepFor <= 0 && *keepPerFeed <= 0 {
+		return nil
+	}
+	return time.Tick(pruneEvery)
+}
+
+// keepSince is when the oldest entry worth keeping was posted,
+// or zero if they're kept however old.
+func keepSince() time.Time {
+	if *keepFor <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-*keepFor)
+}
+
+// retain drops the entries that are older than -keep, or beyond
+// the newest -keep-per-feed of their feed, unless they're starred.
+func retain(entries []Entry) []Entry {
+	since := keepSince()
+	newest := slices.Clone(entries)
+	slices.SortFunc(newest, func(a, b Entry) int {
+		return cmp.Or(b.When.Compare(a.When), cmp.Compare(a.key(), b.key()))
+	})
+	kept := map[string]bool{}
+	perFeed := map[string]int{}
+	for _, e := range newest {
+		if e.Starred {
+			kept[e.key()] = true
+			continue
+		}
+		if e.When.Before(since) {
+			continue
+		}
+		perFeed[e.Source]++
+		if *keepPerFeed <= 0 || perFeed[e.Source] <= *keepPerFeed {
+			kept[e.key()] = true
+		}
+	}
+
+	var retained []Entry
+	for _, e := range entries {
+		if kept[e.key()] {
+			retained = append(retained, e)
+		}
+	}
+	return retained
+}
+
+// prune drops what retain would from entries, saving what's left to st,
+// and from the history in st.
+func prune(st Store, entries []Entry) []Entry {
+	retained := retain(entries)
+	saveFeeds(st, retained)
+	n, err := st.Prune(keepSince(), *keepPerFeed)
+	if err != nil {
+		log.Printf("Couldn't prune old entries: %v\n", err)
+	}
+	if n += len(entries) - len(retained); n > 0 {
+		log.Printf("Pruned %d old entries.\n", n)
+	}
+	return retained
+}
@@ -0,0 +1,32 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefetchedAfterPruning(t *testing.T) {
+	defer func(n int) { *keepPerFeed = n }(*keepPerFeed)
+	*keepPerFeed = 1
+
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	served := []Entry{
+		{Source: "https://blog.example/feed", URL: "https://blog.example/new", GUID: "new", When: day},
+		{Source: "https://blog.example/feed", URL: "https://blog.example/old", GUID: "old", When: day.AddDate(0, 0, -1)},
+	}
+
+	feedz := refetched(served, nil)
+	feedz[0].Read = true
+	feedz = retain(feedz)
+	// The feed still has both entries the next time it's fetched.
+	feedz = refetched(served, feedz)
+
+	if len(feedz) != 1 || feedz[0].GUID != "new" {
+		t.Fatalf("refetched kept %v, want just the newest entry", feedz)
+	}
+	if !feedz[0].Read {
+		t.Errorf("the newest entry is unread again after it was refetched")
+	}
+}
@@ -0,0 +1,95 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var retries = flag.Int("retries", 2, "Number of times to retry a feed that failed for what might be a passing reason")
+var retryWait = flag.Duration("retry-wait", 2*time.Second, "Duration before the first retry, which doubles for each one after")
+
+// badStatus is an error from a server's response status.
+// If the server said when to try again, wait is how long that is.
+type badStatus struct {
+	code int
+	wait time.Duration
+	err  error
+}
+
+func (b badStatus) Error() string {
+	if b.wait > 0 {
+		return fmt.Sprintf("%v, and asked us to wait %v", b.err, b.wait.Round(time.Second))
+	}
+	return b.err.Error()
+}
+
+// transient reports whether err is the sort of failure that might not
+// happen if the request were tried again shortly. It isn't if the server
+// said to wait.
+func transient(err error) bool {
+	var bs badStatus
+	if errors.As(err, &bs) {
+		if bs.wait > 0 {
+			return false
+		}
+		return bs.code >= 500 || bs.code == http.StatusTooManyRequests || bs.code == http.StatusRequestTimeout
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// waitAsked is how long err says the server asked to be left alone, if it did.
+func waitAsked(err error) time.Duration {
+	var bs badStatus
+	if errors.As(err, &bs) {
+		return bs.wait
+	}
+	return 0
+}
+
+// retryAfter is how long a response that's turning us away says to wait,
+// or 0 if it doesn't say.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	ra := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if ra == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(ra); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// loadWithRetries loads the feed listed as s, retrying transient failures
+// with exponential backoff.
+func loadWithRetries(ctx context.Context, s string) ([]Entry, Meta, error) {
+	wait := *retryWait
+	for try := 0; ; try++ {
+		at, a := movedAccess(s)
+		entries, meta, err := loadFeed(ctx, at, a, true)
+		if err == nil || try >= *retries || !transient(err) || ctx.Err() != nil {
+			return entries, meta, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, Meta{}, ctx.Err()
+		}
+		wait *= 2
+	}
+}
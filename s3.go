@@ -0,0 +1,267 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var s3Endpoint = flag.String("s3-endpoint", "https://s3.amazonaws.com", "URL of the S3-compatible service for -store=s3:bucket/key")
+var s3Region = flag.String("s3-region", "us-east-1", "Region of the -s3-endpoint")
+
+// s3Store keeps the entries in the -cache file, like usual, and a copy
+// of it in an S3-compatible bucket, for when the -cache is lost with
+// the machine it was on, with -store=s3:bucket/key. The copy is fetched
+// when it's opened, and written in the background after saves, at most
+// every s3Every, and when it's closed. Credentials are in
+// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY, and maybe $AWS_SESSION_TOKEN.
+type s3Store struct {
+	Store
+	path   string
+	object s3Object
+
+	// saved is sent to when the -cache file is saved, for copy to copy it.
+	saved chan struct{}
+	// closing is closed to stop copy, which closes copied once it has.
+	closing, copied chan struct{}
+}
+
+// s3Every is the least time between copies to the bucket, so saves that
+// come one after another, like entries being marked read, are copied once.
+const s3Every = time.Minute
+
+// openS3 opens the object at where, which is bucket/key,
+// replacing the -cache file with it, if it's there.
+// If it can't be fetched, it's not opened, rather than risk the next save
+// replacing it with whatever's left in the -cache file.
+func openS3(where string) (s3Store, error) {
+	bucket, key, _ := strings.Cut(where, "/")
+	if bucket == "" || key == "" {
+		return s3Store{}, fmt.Errorf("%q isn't bucket/key", where)
+	}
+	o := s3Object{
+		endpoint:  *s3Endpoint,
+		region:    *s3Region,
+		bucket:    bucket,
+		key:       key,
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if o.accessKey == "" || o.secretKey == "" {
+		return s3Store{}, errors.New("I need $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY for s3")
+	}
+	local, err := openCache()
+	if err != nil {
+		return s3Store{}, err
+	}
+
+	b, err := o.get()
+	if err != nil {
+		return s3Store{}, fmt.Errorf("couldn't get s3:%s: %v", where, err)
+	}
+	if b != nil {
+		err = replaceFile(*cache, func(w io.Writer) error {
+			_, err := w.Write(b)
+			return err
+		})
+		if err != nil {
+			return s3Store{}, err
+		}
+	}
+	s := s3Store{
+		Store:   local,
+		path:    *cache,
+		object:  o,
+		saved:   make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		copied:  make(chan struct{}),
+	}
+	go s.copy()
+	return s, nil
+}
+
+// SaveEntries saves the entries to the -cache file,
+// which is copied to the bucket in the background.
+func (s s3Store) SaveEntries(entries []Entry) error {
+	if err := s.Store.SaveEntries(entries); err != nil {
+		return err
+	}
+	select {
+	case s.saved <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close copies the -cache file to the bucket, if it's been saved
+// since it was last copied, and closes it.
+func (s s3Store) Close() error {
+	close(s.closing)
+	<-s.copied
+	return s.Store.Close()
+}
+
+// copy copies the -cache file to the bucket when it's saved,
+// waiting s3Every after each copy, until the store is closing.
+func (s s3Store) copy() {
+	defer close(s.copied)
+	for {
+		select {
+		case <-s.saved:
+		case <-s.closing:
+			select {
+			case <-s.saved:
+				s.put()
+			default:
+			}
+			return
+		}
+		s.put()
+		select {
+		case <-time.After(s3Every):
+		case <-s.closing:
+		}
+	}
+}
+
+// put replaces the object in the bucket with the -cache file.
+func (s s3Store) put() {
+	b, err := os.ReadFile(s.path)
+	if err == nil {
+		err = s.object.put(b)
+	}
+	if err != nil {
+		log.Printf("Couldn't put the cache in s3:%s/%s: %v\n", s.object.bucket, s.object.key, err)
+	}
+}
+
+// s3Object is an object in an S3-compatible bucket,
+// addressed by path, which they all understand.
+type s3Object struct {
+	endpoint, region     string
+	bucket, key          string
+	accessKey, secretKey string
+	token                string
+}
+
+var s3Client = &http.Client{Timeout: time.Minute}
+
+// get is the object's contents, or nil if there isn't one.
+func (o s3Object) get() ([]byte, error) {
+	resp, err := o.do("GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, s3Error(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// put replaces the object's contents with b.
+func (o s3Object) put(b []byte) error {
+	resp, err := o.do("PUT", b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// s3Error describes a failed response, which has an XML error in its body.
+func s3Error(resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("got %s: %s", resp.Status, bytes.TrimSpace(b))
+}
+
+// do sends a request for the object, signed with AWS Signature Version 4.
+func (o s3Object) do(method string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(o.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + o.bucket + "/" + o.key
+	u.RawPath = s3Escape(u.Path)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if o.token != "" {
+		req.Header.Set("X-Amz-Security-Token", o.token)
+		headers = append(headers, "x-amz-security-token")
+	}
+
+	var canonical strings.Builder
+	fmt.Fprintf(&canonical, "%s\n%s\n\n", method, u.RawPath)
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = u.Host
+		}
+		fmt.Fprintf(&canonical, "%s:%s\n", h, v)
+	}
+	signed := strings.Join(headers, ";")
+	fmt.Fprintf(&canonical, "\n%s\n%x", signed, payload)
+
+	scope := day + "/" + o.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + o.secretKey)
+	for _, part := range []string{day, o.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%x",
+		o.accessKey, scope, signed, hmacSHA256(key, toSign)))
+	return s3Client.Do(req)
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes path the way signatures expect: everything but
+// letters, digits, slashes, and -_.~ is percent-encoded.
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			strings.IndexByte("/-_.~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,196 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var adaptive = flag.Bool("adaptive", false, "Poll each feed about as often as it posts, between -min-freq and -max-freq")
+var minFreq = flag.Duration("min-freq", 15*time.Minute, "Shortest duration between polls of a feed, with -adaptive")
+var maxFreq = flag.Duration("max-freq", 24*time.Hour, "Longest duration between polls of a feed, with -adaptive")
+
+// maxPace is the longest a feed's own hints can put off fetching it.
+const maxPace = 7 * 24 * time.Hour
+
+// dueSlack is how early a feed can be fetched, so that feeds due
+// at about the same time are fetched together.
+const dueSlack = time.Minute
+
+// postsObserved is how many of a feed's latest entries its posting rate
+// is judged by.
+const postsObserved = 10
+
+// schedule holds how often feeds are polled, if not every -freq:
+// either given their own interval in the feeds file, or adapted to how
+// often they post. It also holds when each feed should next be fetched.
+var schedule = struct {
+	sync.Mutex
+	every   map[string]time.Duration
+	adapted map[string]time.Duration
+	next    map[string]time.Time
+}{
+	every:   map[string]time.Duration{},
+	adapted: map[string]time.Duration{},
+	next:    map[string]time.Time{},
+}
+
+// pollInterval is how often the feed at u is polled.
+// The schedule must be locked.
+func pollInterval(u string) time.Duration {
+	if d, ok := schedule.every[u]; ok {
+		return d
+	}
+	if d, ok := schedule.adapted[u]; ok && *adaptive {
+		return d
+	}
+	return *freq
+}
+
+// due reports whether it's time to fetch the feed at u.
+func due(u string) bool {
+	schedule.Lock()
+	defer schedule.Unlock()
+	return time.Until(schedule.next[u]) < dueSlack
+}
+
+// hurry makes the feeds at urls due now.
+func hurry(urls ...string) {
+	schedule.Lock()
+	defer schedule.Unlock()
+	for _, u := range urls {
+		delete(schedule.next, u)
+	}
+}
+
+// untilDue is how long until the first of the feeds at urls is due.
+func untilDue(urls []string) time.Duration {
+	schedule.Lock()
+	defer schedule.Unlock()
+	var first time.Time
+	for _, u := range urls {
+		if next := schedule.next[u]; first.IsZero() || next.Before(first) {
+			first = next
+		}
+	}
+	return max(time.Until(first), dueSlack)
+}
+
+// observe adapts how often the feed at u is polled to how often its entries
+// are posted: twice as often as the typical gap between its latest posts,
+// or the time since its last one, if that's longer.
+func observe(u string, entries []Entry) {
+	var times []time.Time
+	for _, e := range entries {
+		if !e.When.IsZero() {
+			times = append(times, e.When)
+		}
+	}
+	if len(times) < 2 {
+		return
+	}
+	slices.SortFunc(times, func(a, b time.Time) int {
+		return b.Compare(a)
+	})
+	times = times[:min(postsObserved, len(times))]
+	gap := times[0].Sub(times[len(times)-1]) / time.Duration(len(times)-1)
+	gap = max(gap, time.Since(times[0]))
+
+	schedule.Lock()
+	defer schedule.Unlock()
+	schedule.adapted[u] = min(max(gap/2, *minFreq), *maxFreq)
+}
+
+// pace holds off fetching the feed at u again until its polling interval
+// has passed, or until hint has, if the feed asked for longer than that.
+func pace(u string, hint time.Duration) {
+	schedule.Lock()
+	defer schedule.Unlock()
+	hint = min(hint, maxPace)
+	// The jitter mustn't bring it sooner than asked.
+	schedule.next[u] = time.Now().Add(max(jitter(max(pollInterval(u), hint)), hint))
+}
+
+// stagger spreads the first fetches of the feeds at urls across their
+// polling intervals, rather than fetching them all at once.
+// Feeds still fresh from before aren't fetched until they're stale,
+// and those the last run had scheduled are fetched when it would have.
+func stagger(urls []string) {
+	for _, u := range urls {
+		fresh := feedStatus(u).FreshUntil
+		saved := savedNext(u)
+		schedule.Lock()
+		next := time.Now().Add(time.Duration(rand.Int63n(int64(pollInterval(u)))))
+		if fresh.After(next) {
+			next = fresh
+		}
+		if saved.After(time.Now()) {
+			next = saved
+		}
+		schedule.next[u] = next
+		schedule.Unlock()
+	}
+}
+
+// jitter varies d by up to a tenth either way, so feeds that were
+// fetched together drift apart.
+func jitter(d time.Duration) time.Duration {
+	spread := int64(d / 5)
+	if spread <= 0 {
+		return d
+	}
+	return d - d/10 + time.Duration(rand.Int63n(spread))
+}
+
+// parseInterval parses a polling interval from the feeds file.
+// It's a Go duration, like 15m or 6h, or a number of days, like 1d.
+func parseInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = errors.New("interval must be positive")
+	}
+	return d, err
+}
+
+// Syndication is the RSS syndication module's update hints.
+// See https://web.resource.org/rss/1.0/modules/syndication/.
+type Syndication struct {
+	UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency int    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+// interval is how often the feed says it's updated, or 0 if it doesn't say.
+func (s Syndication) interval() time.Duration {
+	var period time.Duration
+	switch s.UpdatePeriod {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	case "monthly":
+		period = 30 * 24 * time.Hour
+	case "yearly":
+		period = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+	if s.UpdateFrequency > 1 {
+		period /= time.Duration(s.UpdateFrequency)
+	}
+	return period
+}
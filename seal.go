@@ -0,0 +1,195 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var cacheKey = flag.String("cache-key", "", "Passphrase to encrypt the -cache file and the others kept next to it with; better given by -cache-key-file or $WEBRSS_CACHE_KEY, which ps can't show")
+var cacheKeyFile = flag.String("cache-key-file", "", "File holding the -cache-key")
+var sealFile = flag.String("seal", "", "Encrypt this file, like the feeds file, with the -cache-key, and exit")
+var unsealFile = flag.String("unseal", "", "Write this encrypted file to standard output, decrypted, and exit")
+
+// sealMagic starts a sealed file, so it can be told from a plain one.
+// It's also authenticated along with the contents.
+var sealMagic = []byte("webrss sealed 1\n")
+
+const (
+	saltLen   = 16
+	sealIters = 600000
+)
+
+// sealer holds the key files are sealed with, if there is one.
+// Keys derived from it are kept by salt, since deriving them is slow
+// on purpose. Files written by this run all use the same salt.
+var sealer = struct {
+	sync.Mutex
+	passphrase string
+	salt       []byte
+	keys       map[string][]byte
+}{
+	keys: map[string][]byte{},
+}
+
+// loadSealKey reads the key from -cache-key, -cache-key-file,
+// or $WEBRSS_CACHE_KEY, in that order. With none, files aren't sealed.
+func loadSealKey() error {
+	p := *cacheKey
+	if p == "" && *cacheKeyFile != "" {
+		b, err := os.ReadFile(*cacheKeyFile)
+		if err != nil {
+			return err
+		}
+		p = strings.TrimRight(string(b), "\r\n")
+		if p == "" {
+			return fmt.Errorf("%s is empty", *cacheKeyFile)
+		}
+	}
+	if p == "" {
+		p = os.Getenv("WEBRSS_CACHE_KEY")
+	}
+	if p == "" {
+		return nil
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	sealer.Lock()
+	defer sealer.Unlock()
+	sealer.passphrase = p
+	sealer.salt = salt
+	return nil
+}
+
+// sealing reports whether there's a key to seal files with.
+func sealing() bool {
+	sealer.Lock()
+	defer sealer.Unlock()
+	return sealer.passphrase != ""
+}
+
+// sealAEAD is the cipher for the key with the given salt.
+func sealAEAD(salt []byte) (cipher.AEAD, error) {
+	sealer.Lock()
+	defer sealer.Unlock()
+	if sealer.passphrase == "" {
+		return nil, errors.New("it's encrypted, and I need -cache-key, -cache-key-file, or $WEBRSS_CACHE_KEY to read it")
+	}
+	key, ok := sealer.keys[string(salt)]
+	if !ok {
+		var err error
+		key, err = pbkdf2.Key(sha256.New, sealer.passphrase, salt, sealIters, 32)
+		if err != nil {
+			return nil, err
+		}
+		sealer.keys[string(salt)] = key
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts b with AES-GCM: it's sealMagic, the salt, the nonce,
+// and then the sealed contents.
+func seal(b []byte) ([]byte, error) {
+	sealer.Lock()
+	salt := sealer.salt
+	sealer.Unlock()
+	aead, err := sealAEAD(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append(append([]byte(nil), sealMagic...), salt...), nonce...)
+	return aead.Seal(out, nonce, b, sealMagic), nil
+}
+
+// isSealed reports whether b is the contents of a sealed file.
+func isSealed(b []byte) bool {
+	return bytes.HasPrefix(b, sealMagic)
+}
+
+// unseal decrypts what seal encrypted.
+func unseal(b []byte) ([]byte, error) {
+	b = b[len(sealMagic):]
+	if len(b) < saltLen {
+		return nil, errors.New("it's cut short")
+	}
+	aead, err := sealAEAD(b[:saltLen])
+	if err != nil {
+		return nil, err
+	}
+	b = b[saltLen:]
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("it's cut short")
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], sealMagic)
+	if err != nil {
+		return nil, errors.New("it's encrypted with another key, or it's been tampered with")
+	}
+	return plain, nil
+}
+
+// readFile reads the file at path, decrypting it if it's sealed.
+// Plain files are read as they are, so a cache from before there was
+// a key is still read, and sealed when it's next saved.
+func readFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil || !isSealed(b) {
+		return b, err
+	}
+	plain, err := unseal(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
+
+// sealInPlace replaces the file at path with it sealed.
+func sealInPlace(path string) error {
+	if !sealing() {
+		return errors.New("I need -cache-key, -cache-key-file, or $WEBRSS_CACHE_KEY to encrypt with")
+	}
+	b, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(b)
+	if err != nil {
+		return err
+	}
+	return writeFileAs(path, sealed, info.Mode())
+}
+
+// unsealTo writes the file at path, decrypted, to standard output.
+func unsealTo(path string) error {
+	b, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
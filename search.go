@@ -0,0 +1,104 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// searchLimit is the most entries a search finds.
+const searchLimit = 100
+
+// searchTerms splits a query into the lowercase words that must all be
+// in an entry's title, summary, feed name, or article for it to match.
+func searchTerms(q string) []string {
+	return strings.Fields(strings.ToLower(q))
+}
+
+// matches reports whether e has all the terms.
+func (e Entry) matches(terms []string) bool {
+	text := strings.ToLower(e.Title + "\n" + e.Summary + "\n" + e.FeedName + "\n" + e.Content)
+	for _, t := range terms {
+		if !strings.Contains(text, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchEntries finds up to limit entries matching q, newest first,
+// by looking at every one of them. Stores with no index of their own use it.
+func searchEntries(entries []Entry, q string, limit int) []Entry {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil
+	}
+	var found []Entry
+	for _, e := range entries {
+		if e.matches(terms) {
+			found = append(found, e)
+		}
+	}
+	slices.SortFunc(found, func(a, b Entry) int {
+		return b.When.Compare(a.When)
+	})
+	return found[:min(limit, len(found))]
+}
+
+// searchHandler serves a page of the entries in st matching the query q,
+// or with format=json, just the entries.
+func searchHandler(st Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.FormValue("q")
+		var found []Entry
+		if q != "" {
+			var err error
+			found, err = st.Search(q, searchLimit)
+			if err != nil {
+				log.Printf("Couldn't search for %q: %v\n", q, err)
+				http.Error(w, "The search failed.", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if r.FormValue("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(found)
+			return
+		}
+		searchPage.Execute(w, struct {
+			Query   string
+			Entries []Entry
+			Results Daily
+		}{q, found, bySite(found)})
+	})
+}
+
+var searchPage = newPage("search", searchPageTemplate)
+
+var searchPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Search{{with .Query}}: {{.}}{{end}}</title>
+</head>
+
+<body>
+		<form class="search" method="get" action="search"><input type="search" name="q" value="{{.Query}}" autofocus> <button>Search</button></form>
+{{if .Query}}
+		<p class="details">{{len .Entries}} found for “{{.Query}}”</p>
+{{template "cards" .Results}}
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,49 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// sniffKind describes what the start of a response body looks like,
+// in words fit for an error message.
+func sniffKind(peek []byte) string {
+	b := bytes.TrimLeft(peek, " \t\r\n\ufeff")
+	lower := bytes.ToLower(b[:min(len(b), 64)])
+	switch {
+	case len(b) == 0:
+		return "nothing"
+	case bytes.HasPrefix(lower, []byte("<!doctype html")), bytes.HasPrefix(lower, []byte("<html")):
+		return "HTML"
+	case b[0] == '{' || b[0] == '[':
+		return "JSON"
+	case b[0] == '<':
+		return "XML"
+	}
+
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(peek))
+	switch {
+	case mt == "text/plain":
+		return "plain text"
+	case strings.HasPrefix(mt, "image/"):
+		return "an image"
+	case strings.HasPrefix(mt, "audio/"), strings.HasPrefix(mt, "video/"):
+		return "media"
+	}
+	return mt
+}
+
+// statusError explains a failed response, calling out the
+// Cloudflare challenges that stand in for so many feeds.
+func statusError(resp *http.Response, kind string) error {
+	if resp.Header.Get("Cf-Mitigated") == "challenge" ||
+		(strings.EqualFold(resp.Header.Get("Server"), "cloudflare") && (resp.StatusCode == 403 || resp.StatusCode == 503) && kind == "HTML") {
+		return fmt.Errorf("blocked by a Cloudflare challenge, status %d", resp.StatusCode)
+	}
+	return badStatus{resp.StatusCode, retryAfter(resp), fmt.Errorf("server returned %s, status %d", kind, resp.StatusCode)}
+}
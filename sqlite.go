@@ -0,0 +1,380 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema keeps every entry ever fetched, keyed like Entry.key,
+// so updates to an entry replace it. Only those marked current were in
+// the last save; the rest are history.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	feed_url TEXT NOT NULL,
+	guid TEXT NOT NULL,
+	source TEXT NOT NULL,
+	feed_name TEXT NOT NULL,
+	title TEXT NOT NULL,
+	url TEXT NOT NULL,
+	author TEXT NOT NULL,
+	summary TEXT NOT NULL,
+	posted TIMESTAMP NOT NULL,
+	enclosures TEXT NOT NULL,
+	categories TEXT NOT NULL,
+	thumbnail TEXT NOT NULL,
+	comments TEXT NOT NULL,
+	score INTEGER NOT NULL,
+	comment_count INTEGER NOT NULL,
+	duration INTEGER NOT NULL,
+	episode INTEGER NOT NULL,
+	artwork TEXT NOT NULL,
+	current INTEGER NOT NULL,
+	PRIMARY KEY (feed_url, guid)
+);
+CREATE INDEX IF NOT EXISTS entries_posted ON entries (posted);
+CREATE INDEX IF NOT EXISTS entries_source ON entries (source);
+`
+
+// sqliteStore keeps the entries in an SQLite database,
+// with -store=sqlite:path.
+type sqliteStore struct {
+	db *sql.DB
+	// saved is what was last saved, so only what's changed since is written.
+	saved *sqliteSaved
+}
+
+// sqliteSaved holds the current entries, by their key in the database,
+// as they were last saved.
+type sqliteSaved struct {
+	sync.Mutex
+	entries map[sqliteKey]Entry
+}
+
+// sqliteKey is an entry's primary key in the database.
+type sqliteKey struct {
+	feedURL, guid string
+}
+
+func keyOf(e Entry) sqliteKey {
+	return sqliteKey{e.FeedURL, firstOf(e.GUID, e.URL)}
+}
+
+// sqliteMigrations bring a database made with sqliteSchema up to date,
+// in order. Its user_version is how many it's had.
+var sqliteMigrations = []string{
+	`ALTER TABLE entries ADD COLUMN read INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE entries ADD COLUMN starred INTEGER NOT NULL DEFAULT 0`,
+	// The full-text index, including of the history, kept up by triggers.
+	`CREATE VIRTUAL TABLE entries_fts USING fts4(content="entries", title, summary, feed_name);
+	CREATE TRIGGER entries_fts_bu BEFORE UPDATE OF title, summary, feed_name ON entries BEGIN
+		DELETE FROM entries_fts WHERE docid = old.rowid;
+	END;
+	CREATE TRIGGER entries_fts_bd BEFORE DELETE ON entries BEGIN
+		DELETE FROM entries_fts WHERE docid = old.rowid;
+	END;
+	CREATE TRIGGER entries_fts_au AFTER UPDATE OF title, summary, feed_name ON entries BEGIN
+		INSERT INTO entries_fts (docid, title, summary, feed_name)
+		VALUES (new.rowid, new.title, new.summary, new.feed_name);
+	END;
+	CREATE TRIGGER entries_fts_ai AFTER INSERT ON entries BEGIN
+		INSERT INTO entries_fts (docid, title, summary, feed_name)
+		VALUES (new.rowid, new.title, new.summary, new.feed_name);
+	END;
+	INSERT INTO entries_fts (entries_fts) VALUES ('rebuild');`,
+	// Articles, which are indexed, too.
+	`ALTER TABLE entries ADD COLUMN content TEXT NOT NULL DEFAULT '';
+	DROP TRIGGER entries_fts_bu;
+	DROP TRIGGER entries_fts_bd;
+	DROP TRIGGER entries_fts_au;
+	DROP TRIGGER entries_fts_ai;
+	DROP TABLE entries_fts;
+	CREATE VIRTUAL TABLE entries_fts USING fts4(content="entries", title, summary, feed_name, content);
+	CREATE TRIGGER entries_fts_bu BEFORE UPDATE OF title, summary, feed_name, content ON entries BEGIN
+		DELETE FROM entries_fts WHERE docid = old.rowid;
+	END;
+	CREATE TRIGGER entries_fts_bd BEFORE DELETE ON entries BEGIN
+		DELETE FROM entries_fts WHERE docid = old.rowid;
+	END;
+	CREATE TRIGGER entries_fts_au AFTER UPDATE OF title, summary, feed_name, content ON entries BEGIN
+		INSERT INTO entries_fts (docid, title, summary, feed_name, content)
+		VALUES (new.rowid, new.title, new.summary, new.feed_name, new.content);
+	END;
+	CREATE TRIGGER entries_fts_ai AFTER INSERT ON entries BEGIN
+		INSERT INTO entries_fts (docid, title, summary, feed_name, content)
+		VALUES (new.rowid, new.title, new.summary, new.feed_name, new.content);
+	END;
+	INSERT INTO entries_fts (entries_fts) VALUES ('rebuild');`,
+	// Canonical URLs, so an entry kept from before its URL changed
+	// trivially can be dropped for the current one.
+	`ALTER TABLE entries ADD COLUMN link TEXT NOT NULL DEFAULT '';
+	CREATE INDEX entries_link ON entries (feed_url, link);`,
+	// Feeds and their fetch statuses, which were never read, since
+	// statuses are kept in their own file, whatever the store.
+	`DROP TABLE IF EXISTS feeds;
+	DROP TABLE IF EXISTS fetches;`,
+}
+
+// openSQLite opens the database at path, creating its tables if needed.
+func openSQLite(path string) (sqliteStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return sqliteStore{}, err
+	}
+	// Only one writer at a time, anyway.
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return sqliteStore{}, err
+	}
+	s := sqliteStore{db, &sqliteSaved{}}
+	current, err := s.Entries()
+	if err != nil {
+		db.Close()
+		return sqliteStore{}, err
+	}
+	s.saved.entries = make(map[sqliteKey]Entry, len(current))
+	for _, e := range current {
+		s.saved.entries[keyOf(e)] = e
+	}
+	return s, nil
+}
+
+// migrateSQLite creates the tables in db, if needed,
+// and runs the migrations it hasn't had.
+func migrateSQLite(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for _, m := range sqliteMigrations[min(version, len(sqliteMigrations)):] {
+		if _, err := tx.Exec(m); err != nil {
+			return err
+		}
+	}
+	// PRAGMA doesn't take parameters.
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, len(sqliteMigrations))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveEntries upserts entries, marking them as the current ones.
+// Only those that have changed
+// since the last save are written, and only those that aren't current
+// anymore are marked as history.
+func (s sqliteStore) SaveEntries(entries []Entry) error {
+	s.saved.Lock()
+	defer s.saved.Unlock()
+	current := make(map[sqliteKey]Entry, len(entries))
+	for _, e := range entries {
+		current[keyOf(e)] = e
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	retire, err := tx.Prepare(`UPDATE entries SET current = 0 WHERE feed_url = ? AND guid = ?`)
+	if err != nil {
+		return err
+	}
+	defer retire.Close()
+	for k := range s.saved.entries {
+		if _, ok := current[k]; ok {
+			continue
+		}
+		if _, err := retire.Exec(k.feedURL, k.guid); err != nil {
+			return err
+		}
+	}
+	upsertEntry, err := tx.Prepare(`
+		INSERT INTO entries (feed_url, guid, source, feed_name, title, url, author, summary,
+			posted, enclosures, categories, thumbnail, comments, score, comment_count,
+			duration, episode, artwork, read, starred, content, link, current)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+		ON CONFLICT (feed_url, guid) DO UPDATE SET
+			source = excluded.source, feed_name = excluded.feed_name,
+			title = excluded.title, url = excluded.url, author = excluded.author,
+			summary = excluded.summary, posted = excluded.posted,
+			enclosures = excluded.enclosures, categories = excluded.categories,
+			thumbnail = excluded.thumbnail, comments = excluded.comments,
+			score = excluded.score, comment_count = excluded.comment_count,
+			duration = excluded.duration, episode = excluded.episode,
+			artwork = excluded.artwork, read = excluded.read,
+			starred = excluded.starred, content = excluded.content,
+			link = excluded.link, current = 1`)
+	if err != nil {
+		return err
+	}
+	defer upsertEntry.Close()
+
+	changed := false
+	for k, e := range current {
+		if old, ok := s.saved.entries[k]; ok && reflect.DeepEqual(old, e) {
+			continue
+		}
+		changed = true
+		enclosures, err := json.Marshal(e.Enclosures)
+		if err != nil {
+			return err
+		}
+		categories, err := json.Marshal(e.Categories)
+		if err != nil {
+			return err
+		}
+		_, err = upsertEntry.Exec(k.feedURL, k.guid, e.Source, e.FeedName,
+			e.Title, e.URL, e.Author, e.Summary, e.When.UTC(), string(enclosures),
+			string(categories), e.Thumbnail, e.Comments, e.Score, e.CommentCount,
+			int64(e.Duration), e.Episode, e.Artwork, e.Read, e.Starred, e.Content,
+			canonicalURL(e.URL))
+		if err != nil {
+			return err
+		}
+	}
+	// The entries without GUIDs, which are keyed by their URLs, were
+	// deduped by link, too, so any older one with the same link as
+	// a current one is the same item.
+	if changed {
+		_, err = tx.Exec(`DELETE FROM entries WHERE NOT current AND link != '' AND guid = url AND EXISTS (
+			SELECT 1 FROM entries AS c WHERE c.current AND c.feed_url = entries.feed_url
+				AND c.link = entries.link AND c.guid = c.url)`)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.saved.entries = current
+	return nil
+}
+
+// entryColumns are the columns scanEntries expects.
+const entryColumns = `feed_url, guid, source, feed_name, title, url, author, summary,
+	posted, enclosures, categories, thumbnail, comments, score, comment_count,
+	duration, episode, artwork, read, starred, content`
+
+func (s sqliteStore) Entries() ([]Entry, error) {
+	return scanEntries(s.db.Query(`SELECT ` + entryColumns + ` FROM entries WHERE current`))
+}
+
+// Search looks in the history, too.
+func (s sqliteStore) Search(q string, limit int) ([]Entry, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	// Quoted, the words are just words, not full-text query syntax,
+	// and like the other stores, they can be the starts of words.
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, "") + `*"`
+	}
+	return scanEntries(s.db.Query(`SELECT `+entryColumns+` FROM entries
+		WHERE rowid IN (SELECT docid FROM entries_fts WHERE entries_fts MATCH ?)
+		ORDER BY posted DESC LIMIT ?`, strings.Join(terms, " "), limit))
+}
+
+func (s sqliteStore) Prune(since time.Time, perFeed int) (int, error) {
+	if perFeed <= 0 {
+		perFeed = -1
+	}
+	res, err := s.db.Exec(`
+		DELETE FROM entries WHERE NOT current AND NOT starred AND (posted < ? OR rowid IN (
+			SELECT rowid FROM (
+				SELECT rowid, row_number() OVER (PARTITION BY source ORDER BY posted DESC) AS n
+				FROM entries WHERE NOT starred)
+			WHERE ? >= 0 AND n > ?))`, since.UTC(), perFeed, perFeed)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Compact merges the full-text index's segments, rebuilds the database
+// without the space left by deleted rows, and empties the write-ahead log.
+func (s sqliteStore) Compact() error {
+	for _, q := range []string{
+		`INSERT INTO entries_fts (entries_fts) VALUES ('optimize')`,
+		`VACUUM`,
+		`PRAGMA wal_checkpoint(TRUNCATE)`,
+	} {
+		if _, err := s.db.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup writes a copy of the database made by VACUUM INTO, which sees
+// just one transaction's worth, even while it's being written.
+func (s sqliteStore) Backup(w io.Writer) error {
+	dir, err := os.MkdirTemp("", "webrss-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.db")
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return err
+	}
+	return copyFile(w, path)
+}
+
+func (s sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// scanEntries reads the entryColumns of rows.
+func scanEntries(rows *sql.Rows, err error) ([]Entry, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var enclosures, categories string
+		var duration int64
+		err := rows.Scan(&e.FeedURL, &e.GUID, &e.Source, &e.FeedName, &e.Title, &e.URL,
+			&e.Author, &e.Summary, &e.When, &enclosures, &categories, &e.Thumbnail,
+			&e.Comments, &e.Score, &e.CommentCount, &duration, &e.Episode, &e.Artwork, &e.Read, &e.Starred, &e.Content)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(enclosures), &e.Enclosures); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(categories), &e.Categories); err != nil {
+			return nil, err
+		}
+		e.Duration = time.Duration(duration)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,67 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"slices"
+)
+
+// starHandler stars entries, or unstars them if star=false is posted.
+func starHandler(toMark chan<- mark) http.Handler {
+	return markHandler(toMark, func(r *http.Request) func(*Entry) {
+		starred := r.FormValue("star") != "false"
+		return func(e *Entry) { e.Starred = starred }
+	})
+}
+
+// starred is the starred entries, which are kept even after their feeds
+// drop them.
+func starred(entries []Entry) []Entry {
+	var s []Entry
+	for _, e := range entries {
+		if e.Starred {
+			s = append(s, e)
+		}
+	}
+	return s
+}
+
+func showStarred(w io.Writer, fc <-chan []Entry) {
+	entries := starred(<-fc)
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return b.When.Compare(a.When)
+	})
+	starredPage.Execute(w, entries)
+}
+
+var starredPage = newPage("starred", starredPageTemplate)
+
+var starredPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Starred</title>
+</head>
+
+<body>
+		<div class="card">
+			<h1>★ Starred ★</h1>
+			<ul>
+{{range .}}
+				<li class="card-item">{{template "media" .}}<span class="details">{{with .Author}} by {{.}}{{end}} (<a href="{{.FeedURL}}">{{with icon .FeedURL}}<img class="icon" src="{{.}}" alt="">{{end}}{{.FeedName}}</a>) {{.When.Format "2 Jan 2006"}}</span>{{template "star" .}}{{template "summary" .}}{{template "thumbnail" .}}</li>
+{{else}}
+				<li class="card-item">Nothing yet.</li>
+{{end}}
+			</ul>
+		</div>
+</body>
+</html>
+`
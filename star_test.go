@@ -0,0 +1,58 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStarHandlerRefusesOtherSites(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"same origin", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusSeeOther},
+		{"typed in", map[string]string{"Sec-Fetch-Site": "none"}, http.StatusSeeOther},
+		{"other site", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"same site, other origin", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+		{"old browser, same origin", map[string]string{"Origin": "http://rss.example"}, http.StatusSeeOther},
+		{"old browser, other origin", map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{"no headers", nil, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toMark := make(chan mark, 1)
+			form := url.Values{"entry": {"ep-1"}}
+			r := httptest.NewRequest(http.MethodPost, "http://rss.example/star", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			starHandler(toMark).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			select {
+			case m := <-toMark:
+				if tt.want == http.StatusForbidden {
+					t.Errorf("marked %q from another site", m.ids)
+				}
+				var e Entry
+				m.change(&e)
+				if !e.Starred {
+					t.Errorf("entry wasn't starred")
+				}
+			default:
+				if tt.want != http.StatusForbidden {
+					t.Errorf("nothing was marked")
+				}
+			}
+		})
+	}
+}
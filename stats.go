@@ -0,0 +1,65 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// statsWindow is how many of a feed's latest fetches its statistics cover.
+const statsWindow = 24
+
+// Sample is the cost of one fetch of a feed.
+type Sample struct {
+	When time.Time
+	// Took is how long the request took, from asking to having read it all.
+	Took time.Duration
+	// Parsing is how long it took to make entries of what was read.
+	Parsing time.Duration
+	// Bytes is how much was transferred, before decompressing.
+	Bytes int64
+}
+
+// Typical averages the samples in s's window.
+func (s FeedStatus) Typical() Sample {
+	var t Sample
+	if len(s.Samples) == 0 {
+		return t
+	}
+	for _, x := range s.Samples {
+		t.Took += x.Took
+		t.Parsing += x.Parsing
+		t.Bytes += x.Bytes
+	}
+	n := len(s.Samples)
+	t.When = s.Samples[n-1].When
+	t.Took /= time.Duration(n)
+	t.Parsing /= time.Duration(n)
+	t.Bytes /= int64(n)
+	return t
+}
+
+// measured records the cost of fetching the feed listed as u.
+func measured(u string, m Meta) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	s := statuses.byFeed[u]
+	s.Samples = append(s.Samples, Sample{time.Now(), m.Took, m.Parsing, m.Bytes})
+	if len(s.Samples) > statsWindow {
+		s.Samples = s.Samples[len(s.Samples)-statsWindow:]
+	}
+	statuses.byFeed[u] = s
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
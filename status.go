@@ -0,0 +1,213 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var maxFailures = flag.Int("max-failures", 5, "Number of failures in a row before a feed is only checked daily")
+
+// failingPace is how often a failing feed is checked.
+const failingPace = 24 * time.Hour
+
+// FeedStatus is how fetching a feed has been going.
+type FeedStatus struct {
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+	// StatusCode is the HTTP status of the last response, if there was one.
+	StatusCode int
+	// Items is how many entries the feed had when it was last fetched.
+	Items int
+	// Failures is how many times in a row fetching the feed has failed.
+	Failures int
+	// Missing is how many times in a row the feed wasn't found.
+	Missing int
+	// Retired is when the feed was found to be gone for good,
+	// after which it isn't fetched anymore.
+	Retired time.Time
+	// Samples are the costs of the latest fetches.
+	Samples []Sample
+	// FreshUntil is when the server said the feed could stop being cached.
+	FreshUntil time.Time
+	// MovedTo is where the feed has permanently redirected, if it has.
+	MovedTo string
+	// Next is when the feed is next due to be fetched.
+	Next time.Time
+}
+
+// OK reports whether the last fetch of the feed worked.
+func (s FeedStatus) OK() bool {
+	return s.Failures == 0
+}
+
+// Failing reports whether the feed has failed too many times in a row,
+// and so is only checked daily.
+func (s FeedStatus) Failing() bool {
+	return s.Failures > 0 && s.Failures >= *maxFailures
+}
+
+// statuses holds the status of each feed, by the URL it's listed as.
+var statuses = struct {
+	sync.Mutex
+	byFeed map[string]FeedStatus
+}{
+	byFeed: map[string]FeedStatus{},
+}
+
+// statusFile is where statuses are kept, next to the feed cache.
+func statusFile() string {
+	return filepath.Join(filepath.Dir(*cache), "status.gob")
+}
+
+// loadStatuses reads the statuses saved by the last run.
+func loadStatuses() {
+	b, err := readFile(statusFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		log.Printf("Couldn't read %s: %v\n", statusFile(), err)
+		return
+	}
+	statuses.Lock()
+	defer statuses.Unlock()
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&statuses.byFeed)
+	if err != nil {
+		log.Printf("Couldn't read %s: %v\n", statusFile(), err)
+	}
+
+	moved.Lock()
+	defer moved.Unlock()
+	for u, s := range statuses.byFeed {
+		if s.MovedTo != "" {
+			moved.to[u] = s.MovedTo
+		}
+	}
+}
+
+// saveStatuses writes the statuses, along with when each feed is next due,
+// so a restart picks up where this run left off.
+func saveStatuses() {
+	schedule.Lock()
+	next := maps.Clone(schedule.next)
+	schedule.Unlock()
+
+	statuses.Lock()
+	defer statuses.Unlock()
+	for u, s := range statuses.byFeed {
+		s.Next = next[u]
+		statuses.byFeed[u] = s
+	}
+	err := replaceFile(statusFile(), func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(statuses.byFeed)
+	})
+	if err != nil {
+		log.Printf("Couldn't save statuses: %v\n", err)
+	}
+}
+
+// fresh records that the feed listed as u can be cached for d.
+func fresh(u string, d time.Duration) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	s := statuses.byFeed[u]
+	s.FreshUntil = time.Now().Add(d)
+	statuses.byFeed[u] = s
+}
+
+// fetched records that the feed listed as u was fetched with the given
+// HTTP status. Unless it was unchanged, it had n entries.
+func fetched(u string, code, n int) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	s := statuses.byFeed[u]
+	if s.Failing() {
+		log.Printf("%s works again.\n", u)
+	}
+	s.LastSuccess = time.Now()
+	s.StatusCode = code
+	s.Failures = 0
+	s.Missing = 0
+	if code != http.StatusNotModified {
+		s.Items = n
+	}
+	statuses.byFeed[u] = s
+}
+
+// failed records that fetching the feed listed as u failed with err,
+// and reports whether it's failed enough times in a row to be considered failing.
+func failed(u string, err error) bool {
+	statuses.Lock()
+	defer statuses.Unlock()
+	s := statuses.byFeed[u]
+	s.LastFailure = time.Now()
+	s.LastError = err.Error()
+	s.Failures++
+	if s.Failures == *maxFailures {
+		log.Printf("%s has failed %d times in a row. It'll be checked daily until it works.\n", u, s.Failures)
+	}
+	s.StatusCode = 0
+	var bs badStatus
+	if errors.As(err, &bs) {
+		s.StatusCode = bs.code
+	}
+	if s.StatusCode == http.StatusNotFound {
+		s.Missing++
+	} else {
+		s.Missing = 0
+	}
+	if s.StatusCode == http.StatusGone || (s.Missing > 0 && s.Missing >= *maxFailures) {
+		s.Retired = time.Now()
+		log.Printf("%s is gone, so it's retired. It won't be fetched again unless it's refreshed.\n", u)
+	}
+	statuses.byFeed[u] = s
+	return s.Failing()
+}
+
+// retired reports whether the feed listed as u is gone for good.
+func retired(u string) bool {
+	statuses.Lock()
+	defer statuses.Unlock()
+	return !statuses.byFeed[u].Retired.IsZero()
+}
+
+// revive lets the feed listed as u, if it was retired, be fetched again.
+func revive(u string) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	s := statuses.byFeed[u]
+	s.Retired = time.Time{}
+	s.Missing = 0
+	statuses.byFeed[u] = s
+}
+
+// savedNext is when the feed listed as u was next due when the statuses
+// were saved.
+func savedNext(u string) time.Time {
+	statuses.Lock()
+	defer statuses.Unlock()
+	return statuses.byFeed[u].Next
+}
+
+// feedStatus returns the status of the feed listed as u.
+func feedStatus(u string) FeedStatus {
+	statuses.Lock()
+	s := statuses.byFeed[u]
+	statuses.Unlock()
+	schedule.Lock()
+	s.Next = schedule.next[u]
+	schedule.Unlock()
+	return s
+}
@@ -0,0 +1,282 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A Store keeps the entries between runs.
+type Store interface {
+	// SaveEntries replaces the current entries with entries.
+	SaveEntries(entries []Entry) error
+	// Entries is the current entries, or nil if none have been saved.
+	Entries() ([]Entry, error)
+	// Search finds up to limit entries, newest first, with all the words
+	// in q in their titles, summaries, feed names, or articles.
+	Search(q string, limit int) ([]Entry, error)
+	// Prune drops the entries kept apart from the current ones that were
+	// posted before since, or are beyond the newest perFeed of their feed,
+	// if perFeed isn't 0. Starred ones are kept. It says how many it dropped.
+	Prune(since time.Time, perFeed int) (int, error)
+	// Compact gives back the space left by entries that were dropped.
+	Compact() error
+	// Backup writes a copy of the store, which restores it when it's
+	// put where the store is kept.
+	Backup(w io.Writer) error
+	Close() error
+}
+
+var cacheFormat = flag.String("cache-format", "gob", "Format of the -cache file: gob, or json for something readable")
+
+// openStore opens the -store, or the -cache file if there isn't one.
+func openStore() (Store, error) {
+	if *store == "" {
+		return openCache()
+	}
+	kind, path, _ := strings.Cut(*store, ":")
+	if sealing() && kind != "s3" {
+		return nil, errors.New("a -cache-key only encrypts the -cache file and those next to it, not a -store")
+	}
+	switch {
+	case path == "":
+	case kind == "s3":
+		return openS3(path)
+	case kind == "sqlite":
+		return openSQLite(path)
+	case kind == "bolt":
+		return openBolt(path)
+	}
+	return nil, fmt.Errorf("I don't know how to store entries in %q", *store)
+}
+
+// openCache opens the -cache file, in the -cache-format.
+func openCache() (Store, error) {
+	switch *cacheFormat {
+	case "gob":
+		return gobStore{*cache}, nil
+	case "json":
+		return jsonStore{*cache}, nil
+	}
+	return nil, fmt.Errorf("I don't know the %q cache format", *cacheFormat)
+}
+
+// cacheVersion starts the gob file. Bump it when Entry changes in a way
+// gob can't cope with, like a field changing type, and teach
+// gobStore.Entries to read the old version.
+const cacheVersion = 1
+
+// gobStore keeps the entries in a gob file at path.
+type gobStore struct {
+	path string
+}
+
+func (s gobStore) SaveEntries(entries []Entry) error {
+	return replaceFile(s.path, func(w io.Writer) error {
+		enc := gob.NewEncoder(w)
+		if err := enc.Encode(cacheVersion); err != nil {
+			return err
+		}
+		return enc.Encode(entries)
+	})
+}
+
+// Entries reads the file. If it can't be read, the feeds are better
+// refetched than not, so there are no entries.
+func (s gobStore) Entries() ([]Entry, error) {
+	b, err := readFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	f := bytes.NewReader(b)
+
+	dec := gob.NewDecoder(f)
+	var version int
+	if dec.Decode(&version) != nil {
+		// Files from before there were versions are just the entries.
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		dec = gob.NewDecoder(f)
+		version = 0
+	}
+
+	var entries []Entry
+	switch version {
+	case 0, cacheVersion:
+		err = dec.Decode(&entries)
+	default:
+		err = fmt.Errorf("it's version %d, and I only know up to %d", version, cacheVersion)
+	}
+	if err != nil {
+		log.Printf("Couldn't read the cached feeds, so I'll refetch them: %v\n", err)
+		return nil, nil
+	}
+	return entries, nil
+}
+
+func (s gobStore) Search(q string, limit int) ([]Entry, error) {
+	entries, err := s.Entries()
+	return searchEntries(entries, q, limit), err
+}
+
+// Prune has nothing to do, since the file only has the current entries.
+func (s gobStore) Prune(since time.Time, perFeed int) (int, error) {
+	return 0, nil
+}
+
+// Compact has nothing to do, since the file is rewritten whole
+// every time it's saved.
+func (s gobStore) Compact() error {
+	return nil
+}
+
+// Backup copies the file, which is never half-written, as it is.
+func (s gobStore) Backup(w io.Writer) error {
+	return copyFile(w, s.path)
+}
+
+func (s gobStore) Close() error {
+	return nil
+}
+
+// jsonStore keeps the entries in a JSON file at path, with -cache-format=json,
+// for grepping and hand-editing.
+type jsonStore struct {
+	path string
+}
+
+// jsonCache is what's in a jsonStore's file.
+type jsonCache struct {
+	Version int
+	Entries []Entry
+}
+
+func (s jsonStore) SaveEntries(entries []Entry) error {
+	return replaceFile(s.path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(jsonCache{cacheVersion, entries})
+	})
+}
+
+// Entries reads the file. Like gobStore's, if it can't be read,
+// there are no entries.
+func (s jsonStore) Entries() ([]Entry, error) {
+	b, err := readFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var c jsonCache
+	err = json.Unmarshal(b, &c)
+	if err == nil && c.Version > cacheVersion {
+		err = fmt.Errorf("it's version %d, and I only know up to %d", c.Version, cacheVersion)
+	}
+	if err != nil {
+		log.Printf("Couldn't read the cached feeds, so I'll refetch them: %v\n", err)
+		return nil, nil
+	}
+	return c.Entries, nil
+}
+
+func (s jsonStore) Search(q string, limit int) ([]Entry, error) {
+	entries, err := s.Entries()
+	return searchEntries(entries, q, limit), err
+}
+
+func (s jsonStore) Prune(since time.Time, perFeed int) (int, error) {
+	return 0, nil
+}
+
+func (s jsonStore) Compact() error {
+	return nil
+}
+
+func (s jsonStore) Backup(w io.Writer) error {
+	return copyFile(w, s.path)
+}
+
+// copyFile writes the file at path to w, or nothing if there isn't one.
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (s jsonStore) Close() error {
+	return nil
+}
+
+// replaceFile writes a new file with write and renames it over the one
+// at path, so that's never left half-written. With a -cache-key, it's sealed.
+func replaceFile(path string, write func(io.Writer) error) error {
+	if !sealing() {
+		return swapFile(path, func(f *os.File) error { return write(f) })
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	sealed, err := seal(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return swapFile(path, func(f *os.File) error {
+		_, err := f.Write(sealed)
+		return err
+	})
+}
+
+// writeFileAs replaces the file at path with b, like replaceFile,
+// but as it is, with the given permissions.
+func writeFileAs(path string, b []byte, mode fs.FileMode) error {
+	return swapFile(path, func(f *os.File) error {
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+		return f.Chmod(mode)
+	})
+}
+
+// swapFile writes a temporary file next to path and renames it over path.
+func swapFile(path string, write func(*os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(dir string) (Store, error)
+	}{
+		{"gob", func(dir string) (Store, error) { return gobStore{filepath.Join(dir, "rss.gob")}, nil }},
+		{"json", func(dir string) (Store, error) { return jsonStore{filepath.Join(dir, "rss.json")}, nil }},
+		{"bolt", func(dir string) (Store, error) { return openBolt(filepath.Join(dir, "rss.bolt")) }},
+		{"sqlite", func(dir string) (Store, error) { return openSQLite(filepath.Join(dir, "rss.db")) }},
+	}
+
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{
+			FeedName:   "Podcast",
+			FeedURL:    "https://podcast.example/",
+			Title:      "Episode 1",
+			URL:        "https://podcast.example/1",
+			Author:     "Host",
+			Summary:    "The first one.",
+			When:       day.Add(2 * time.Hour),
+			Enclosures: []Enclosure{{"https://podcast.example/1.mp3", "audio/mpeg", 1234}},
+			Categories: []string{"talk", "tech"},
+			GUID:       "ep-1",
+			Source:     "https://podcast.example/feed.xml",
+			Duration:   42 * time.Minute,
+			Episode:    1,
+			Artwork:    "https://podcast.example/art.png",
+		},
+		{
+			FeedName:     "Links",
+			FeedURL:      "https://links.example/",
+			Title:        "A link",
+			URL:          "https://elsewhere.example/story",
+			When:         day.Add(26 * time.Hour),
+			GUID:         "https://links.example/item/7",
+			Thumbnail:    "https://links.example/thumb.jpg",
+			Comments:     "https://links.example/item/7#comments",
+			Score:        99,
+			CommentCount: 12,
+			Source:       "https://links.example/rss",
+			Read:         true,
+			Starred:      true,
+			Content:      "First paragraph.\n\nSecond paragraph.",
+		},
+		{
+			FeedName: "Blog",
+			FeedURL:  "https://blog.example/",
+			Title:    "Old post",
+			URL:      "https://blog.example/old",
+			When:     day.Add(-48 * time.Hour),
+			Source:   "https://blog.example/atom.xml",
+		},
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			st, err := s.open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+
+			if got, err := st.Entries(); err != nil || got != nil {
+				t.Fatalf("Entries() before saving = %v, %v; want nil, nil", got, err)
+			}
+
+			if err := st.SaveEntries(entries); err != nil {
+				t.Fatalf("SaveEntries: %v", err)
+			}
+			got, err := st.Entries()
+			if err != nil {
+				t.Fatalf("Entries: %v", err)
+			}
+			if want := entries; !sameEntries(got, want) {
+				t.Errorf("Entries() = %v\nwant %v", titlesOf(got), titlesOf(want))
+			}
+
+			// Saving again replaces what's current.
+			changed := slices.Clone(entries[1:])
+			changed[0].Read = false
+			changed[0].Title = "A link, retitled"
+			if err := st.SaveEntries(changed); err != nil {
+				t.Fatalf("SaveEntries again: %v", err)
+			}
+			got, err = st.Entries()
+			if err != nil {
+				t.Fatalf("Entries after saving again: %v", err)
+			}
+			if !sameEntries(got, changed) {
+				t.Errorf("Entries() after saving again = %v\nwant %v", titlesOf(got), titlesOf(changed))
+			}
+		})
+	}
+}
+
+// sameEntries reports whether got and want have the same entries,
+// in any order, with their times compared as instants. Entries without
+// a GUID may come back with their URL as one, since they're keyed by it.
+func sameEntries(got, want []Entry) bool {
+	normal := func(es []Entry) []Entry {
+		es = slices.Clone(es)
+		for i := range es {
+			es[i].When = es[i].When.UTC()
+			es[i].GUID = firstOf(es[i].GUID, es[i].URL)
+		}
+		slices.SortFunc(es, func(a, b Entry) int { return strings.Compare(a.URL, b.URL) })
+		return es
+	}
+	return reflect.DeepEqual(normal(got), normal(want))
+}
+
+func titlesOf(entries []Entry) []string {
+	var titles []string
+	for _, e := range entries {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+func TestSQLiteKeepsEntriesSharingALink(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "rss.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var episodes []Entry
+	for _, guid := range []string{"ep-1", "ep-2", "ep-3", "ep-4"} {
+		episodes = append(episodes, Entry{
+			FeedURL: "https://show.example/",
+			Title:   guid,
+			URL:     "https://show.example/",
+			GUID:    guid,
+			When:    time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			Source:  "https://show.example/feed.xml",
+		})
+	}
+	if err := s.SaveEntries(episodes[:3]); err != nil {
+		t.Fatal(err)
+	}
+	// The older episodes drop out of the feed as a new one comes,
+	// but they're kept as history.
+	if err := s.SaveEntries(episodes[2:]); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT count(*) FROM entries`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("%d entries kept, want 4", n)
+	}
+}
+
+func TestBoltLeavesOutEntriesWithoutASource(t *testing.T) {
+	s, err := openBolt(filepath.Join(t.TempDir(), "rss.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	fed := Entry{FeedURL: "https://blog.example/", Title: "Fed", URL: "https://blog.example/1", When: day, Source: "https://blog.example/feed"}
+	orphan := Entry{FeedURL: "https://gone.example/", Title: "Orphan", URL: "https://gone.example/1", When: day}
+	if err := s.SaveEntries([]Entry{fed, orphan}); err != nil {
+		t.Fatalf("SaveEntries: %v", err)
+	}
+	got, err := s.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Entry{fed}; !sameEntries(got, want) {
+		t.Errorf("Entries() = %v, want %v", titlesOf(got), titlesOf(want))
+	}
+}
@@ -0,0 +1,66 @@
+// The service worker keeps a copy of the days' pages and the styles that
+// are read, so they're there offline, like the last day shown, with its
+// summaries. Pages are fetched fresh when they can be. Nothing else is
+// kept, like the admin pages or exports, and only so many pages are.
+
+const cache = 'webrss-2';
+
+// precached are kept from the start, and never let go.
+const precached = ['./', 'style/feed.css', 'style/charter.css', 'theme.css'];
+
+// maxKept is how many responses are kept, after which the oldest go.
+const maxKept = 60;
+
+self.addEventListener('install', event => {
+	event.waitUntil(caches.open(cache).then(c => c.addAll(precached)));
+	self.skipWaiting();
+});
+
+// Older caches may have anything that was read, so they're dropped.
+self.addEventListener('activate', event => {
+	event.waitUntil(caches.keys()
+		.then(names => Promise.all(names.filter(n => n !== cache).map(n => caches.delete(n))))
+		.then(() => self.clients.claim()));
+});
+
+// kept reports whether the response to a GET of u is worth keeping:
+// a day's page or a style.
+function kept(u) {
+	const scope = new URL(self.registration.scope);
+	if (u.origin !== scope.origin || !u.pathname.startsWith(scope.pathname)) {
+		return false;
+	}
+	const path = u.pathname.slice(scope.pathname.length);
+	return path === '' || path === 'day' || path.startsWith('day/') || path === 'yesterday' ||
+		path === 'theme.css' || path.startsWith('style/');
+}
+
+// trim drops the oldest responses beyond maxKept, other than those precached.
+async function trim(c) {
+	const always = new Set(precached.map(p => new URL(p, self.registration.scope).href));
+	const keys = (await c.keys()).filter(req => !always.has(req.url));
+	await Promise.all(keys.slice(0, Math.max(0, keys.length - maxKept)).map(req => c.delete(req)));
+}
+
+self.addEventListener('fetch', event => {
+	const req = event.request;
+	if (req.method !== 'GET' || !kept(new URL(req.url))) {
+		return;
+	}
+	event.respondWith(fetch(req).then(resp => {
+		if (resp.ok) {
+			const copy = resp.clone();
+			event.waitUntil(caches.open(cache).then(c => c.put(req, copy).then(() => trim(c))));
+		}
+		return resp;
+	}).catch(() => caches.match(req).then(cached => {
+		if (cached) {
+			return cached;
+		}
+		// A page that wasn't read before gets the last day that was.
+		if (req.mode === 'navigate') {
+			return caches.match('./');
+		}
+		return Response.error();
+	})));
+});
@@ -0,0 +1,144 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var templateDir = flag.String("templates", "", "Directory of templates to use instead of the built-in ones, like daily.html for the daily page; they're reloaded when they change")
+
+// A page is the template for a kind of page, named like daily. It's
+// the built-in template, then name.html in the -templates, if there is one,
+// which replaces it, or just the templates it defines.
+type page struct {
+	name, text string
+
+	mu sync.RWMutex
+	t  *template.Template
+}
+
+// pageList is every page, so they can all be reloaded together.
+var pageList []*page
+
+// newPage makes a page from its built-in template.
+// It can't be shown until loadPages loads it.
+func newPage(name, text string) *page {
+	p := &page{name: name, text: text}
+	pageList = append(pageList, p)
+	return p
+}
+
+// Execute shows the page with data.
+func (p *page) Execute(w io.Writer, data any) error {
+	p.mu.RLock()
+	t := p.t
+	p.mu.RUnlock()
+	return t.Execute(w, data)
+}
+
+// loadPages parses every page. The others are parsed along with
+// the daily page's, so they can use the templates it defines,
+// like cards. If any fails, none are replaced.
+func loadPages() error {
+	base, err := parsePage(template.New(dailyPage.name).Funcs(template.FuncMap{
+		"icon":   iconPath,
+		"muted":  mutedFeeds,
+		"failed": failedFeeds,
+		"day":    dayLink,
+	}), dailyPage)
+	if err != nil {
+		return err
+	}
+	parsed := map[*page]*template.Template{}
+	for _, p := range pageList {
+		t := template.Must(base.Clone())
+		if p != dailyPage {
+			if t, err = parsePage(t.New(p.name), p); err != nil {
+				return err
+			}
+		}
+		parsed[p] = t
+	}
+	for p, t := range parsed {
+		p.mu.Lock()
+		p.t = t
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// parsePage parses p's templates into t.
+func parsePage(t *template.Template, p *page) (*template.Template, error) {
+	t, err := t.Parse(p.text)
+	if err != nil {
+		return nil, err
+	}
+	if *templateDir == "" {
+		return t, nil
+	}
+	path := filepath.Join(*templateDir, p.name+".html")
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if t, err = t.Parse(string(b)); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+// watchPages reloads the pages whenever the -templates change,
+// until ctx is done. Templates that don't parse are logged,
+// and the pages are left as they were.
+func watchPages(ctx context.Context) {
+	last := templatesChanged()
+	tick := time.NewTicker(2 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		changed := templatesChanged()
+		if changed.Equal(last) {
+			continue
+		}
+		last = changed
+		if err := loadPages(); err != nil {
+			log.Printf("Couldn't reload the templates: %v\n", err)
+			continue
+		}
+		log.Printf("Reloaded the templates.\n")
+	}
+}
+
+// templatesChanged is when the -templates last changed: when a template
+// in it was last written, or when one was last added or removed.
+func templatesChanged() time.Time {
+	var latest time.Time
+	if info, err := os.Stat(*templateDir); err == nil {
+		latest = info.ModTime()
+	}
+	entries, _ := os.ReadDir(*templateDir)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
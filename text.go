@@ -0,0 +1,109 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// summaryLen is the most runes of an item's summary or content kept on its Entry.
+const summaryLen = 500
+
+// teaserLen is how much of a summary is shown before it's expanded.
+const teaserLen = 200
+
+var scriptish = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>`)
+var tags = regexp.MustCompile(`(?s)<!--.*?-->|</?[a-zA-Z][^>]*>`)
+
+// invisibles are characters that feeds sprinkle into text without meaning to.
+// Zero-width joiners are left alone, since emoji and some scripts need them.
+var invisibles = strings.NewReplacer(
+	"\u00ad", "", // soft hyphen
+	"\u200b", "", // zero-width space
+	"\u2060", "", // word joiner
+	"\ufeff", "", // zero-width no-break space
+)
+
+// plainText turns a snippet of feed HTML into plain text:
+// no tags, no entities, no invisible characters, and no runs of whitespace,
+// in Unicode normal form C so the same name from different feeds compares equal.
+func plainText(s string) string {
+	s = scriptish.ReplaceAllString(s, " ")
+	s = tags.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	s = invisibles.Replace(s)
+	s = norm.NFC.String(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cleanTitles makes the feed and item titles of entries presentable.
+// They often arrive with entities, markup, or stray newlines.
+func cleanTitles(entries []Entry) {
+	for i := range entries {
+		entries[i].FeedName = plainText(entries[i].FeedName)
+		entries[i].Title = plainText(entries[i].Title)
+		entries[i].Author = plainText(entries[i].Author)
+		for j, c := range entries[i].Categories {
+			entries[i].Categories[j] = plainText(c)
+		}
+	}
+}
+
+// titleLen is the most runes of a summary used for an entry without a title.
+const titleLen = 80
+
+// addTitles gives untitled entries, like microblog posts, the start of their
+// summary as a title, so they aren't blank links. If that's all the summary
+// there is, it's dropped rather than shown twice.
+func addTitles(entries []Entry) {
+	for i := range entries {
+		e := &entries[i]
+		if e.Title != "" {
+			continue
+		}
+		e.Title = excerpt(e.Summary, titleLen)
+		if e.Title == e.Summary {
+			e.Summary = ""
+		}
+	}
+}
+
+// Teaser is the start of the entry's summary.
+func (e Entry) Teaser() string {
+	return excerpt(e.Summary, teaserLen)
+}
+
+// excerpt returns the plain text of s, cut to at most n runes on a word boundary.
+func excerpt(s string, n int) string {
+	s = plainText(s)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	cut, r := 0, 0
+	for i := range s {
+		if r == n {
+			cut = i
+			break
+		}
+		r++
+	}
+	if sp := strings.LastIndexByte(s[:cut], ' '); sp > 0 {
+		cut = sp
+	}
+	return s[:cut] + "…"
+}
+
+// firstOf returns the first non-empty string.
+func firstOf(ss ...string) string {
+	for _, s := range ss {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+)
+
+// themeCookie holds the theme picked on the daily page: light or dark.
+// Without it, the theme is the system's.
+const themeCookie = "theme"
+
+// themeStyle serves the style of the theme picked: dark.css, from the style
+// files, for dark, nothing for light, or dark.css when the system's is dark.
+func themeStyle(w http.ResponseWriter, r *http.Request) {
+	dark, err := fs.ReadFile(styleFiles(), "dark.css")
+	if err != nil {
+		log.Printf("Couldn't read the dark theme: %v\n", err)
+		http.Error(w, "The theme is missing.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Vary", "Cookie")
+	theme := ""
+	if c, err := r.Cookie(themeCookie); err == nil {
+		theme = c.Value
+	}
+	switch theme {
+	case "light":
+	case "dark":
+		w.Write(dark)
+	default:
+		fmt.Fprintf(w, "@media (prefers-color-scheme: dark) {\n%s}\n", dark)
+	}
+}
+
+// themeHandler picks the theme posted to it, or the system's
+// when it's neither light nor dark, and sends it back to the page
+// it came from.
+func themeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Picking a theme takes a POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	c := &http.Cookie{
+		Name:     themeCookie,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch theme := r.FormValue("theme"); theme {
+	case "light", "dark":
+		c.Value = theme
+		c.Expires = time.Now().AddDate(1, 0, 0)
+	default:
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+	back := r.Referer()
+	if back == "" {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
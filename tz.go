@@ -0,0 +1,62 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var tz = flag.String("tz", "UTC", "Time zone that days start and end in, like America/Chicago, or Local for the system's")
+var dayStart = flag.Duration("day-start", 0, "Time of day that days start at, like 4h, so what's read late at night is still part of the day before")
+
+// zone is the -tz, once loadZone has loaded it.
+var zone = time.UTC
+
+// loadZone loads the -tz, and checks the -day-start is in a day.
+func loadZone() error {
+	if *dayStart < 0 || *dayStart >= 24*time.Hour {
+		return fmt.Errorf("-day-start %v isn't between 0 and 24h", *dayStart)
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	zone = loc
+	return nil
+}
+
+// dayOf is when the date t is on starts, at the -day-start, in t's time zone.
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	// The -day-start is counted on the clock, so it's the same hour
+	// on days that are longer or shorter for daylight saving time.
+	return time.Date(y, m, d, 0, 0, 0, int(*dayStart), t.Location())
+}
+
+// startOfDay is when the day t is in started. Before the -day-start,
+// that's the day before's.
+func startOfDay(t time.Time) time.Time {
+	return dayOf(t.Add(-*dayStart))
+}
+
+// latestDay is when the main page starts: a day ago, in the -tz,
+// so it has everything up to now, including what's new today.
+func latestDay() time.Time {
+	return time.Now().In(zone).AddDate(0, 0, -1)
+}
+
+// dayLink is the day that t is in, in the -tz, like in day?date=2021-06-01.
+func dayLink(t time.Time) string {
+	return startOfDay(t.In(zone)).Format(dayFormat)
+}
+
+// parseDay parses a day like 2021-06-01, starting in the -tz.
+func parseDay(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(dayFormat, s, zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return dayOf(t), nil
+}
@@ -0,0 +1,217 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"flag"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var websubURL = flag.String("websub", "", "Public URL of this server, for receiving WebSub pushes (disabled if empty)")
+
+// leaseTime is how long hubs are asked to keep subscriptions.
+const leaseTime = 10 * 24 * time.Hour
+
+// maxPushSize is the most of a pushed update that's read.
+const maxPushSize = 10 << 20
+
+// A subscription is to one feed (the topic) via its hub.
+// They're kept in memory only; after a restart, the next fetch
+// of each feed subscribes anew.
+type subscription struct {
+	Source  string
+	Topic   string
+	Hub     string
+	Secret  string
+	Asked   time.Time
+	Expires time.Time // Zero until the hub verifies.
+}
+
+var subs = struct {
+	sync.Mutex
+	byID    map[string]*subscription
+	byTopic map[string]string
+}{
+	byID:    map[string]*subscription{},
+	byTopic: map[string]string{},
+}
+
+// subscribe asks hub to push updates to topic, the feed fetched from source,
+// unless it already does or has been asked recently.
+func subscribe(source, topic, hub string) {
+	if *websubURL == "" {
+		return
+	}
+
+	now := time.Now()
+	subs.Lock()
+	id, ok := subs.byTopic[topic]
+	sub := subs.byID[id]
+	if ok && sub.Hub == hub {
+		verified := sub.Expires.After(now.Add(24 * time.Hour))
+		pending := sub.Expires.IsZero() && now.Sub(sub.Asked) < time.Hour
+		if verified || pending {
+			subs.Unlock()
+			return
+		}
+	}
+	if !ok {
+		id = randomHex(16)
+		sub = &subscription{Topic: topic, Secret: randomHex(32)}
+		subs.byID[id] = sub
+		subs.byTopic[topic] = id
+	}
+	sub.Source = source
+	sub.Hub = hub
+	sub.Asked = now
+	secret := sub.Secret
+	subs.Unlock()
+
+	callback := strings.TrimSuffix(*websubURL, "/") + "/websub/" + id
+	resp, err := newClient().PostForm(hub, url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.callback":      {callback},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(int(leaseTime.Seconds()))},
+	})
+	if err != nil {
+		log.Printf("WebSub subscription to %s failed: %v\n", topic, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("WebSub subscription to %s failed: %s\n", topic, resp.Status)
+	}
+}
+
+// websubHandler serves subscription callbacks, which are at
+// the subscription's ID. Hubs GET them to verify the subscription
+// and POST them with updated content, which is sent to toAdd.
+func websubHandler(toAdd chan<- []Entry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subs.Lock()
+		sub, ok := subs.byID[r.URL.Path]
+		var s subscription
+		if ok {
+			s = *sub
+		}
+		subs.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			verifySubscription(w, r, s)
+		case "POST":
+			receivePush(w, r, s, toAdd)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func verifySubscription(w http.ResponseWriter, r *http.Request, s subscription) {
+	q := r.URL.Query()
+	if q.Get("hub.topic") != s.Topic {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch q.Get("hub.mode") {
+	case "subscribe":
+		lease, err := strconv.Atoi(q.Get("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			lease = int(leaseTime.Seconds())
+		}
+		subs.Lock()
+		if sub, ok := subs.byID[r.URL.Path]; ok {
+			sub.Expires = time.Now().Add(time.Duration(lease) * time.Second)
+		}
+		subs.Unlock()
+		io.WriteString(w, q.Get("hub.challenge"))
+	case "denied":
+		log.Printf("WebSub hub denied subscription to %s: %s\n", s.Topic, q.Get("hub.reason"))
+	default:
+		// Nobody asked to unsubscribe.
+		http.NotFound(w, r)
+	}
+}
+
+func receivePush(w http.ResponseWriter, r *http.Request, s subscription, toAdd chan<- []Entry) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Per the spec, bad signatures are acknowledged but ignored.
+	if !validSignature(r.Header.Get("X-Hub-Signature"), s.Secret, body) {
+		log.Printf("WebSub push for %s has a bad signature\n", s.Topic)
+		return
+	}
+
+	base, _ := url.Parse(s.Topic)
+	entries, _, err := tryParse(base, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("WebSub push for %s: %v\n", s.Topic, err)
+		return
+	}
+	for i := range entries {
+		entries[i].Source = s.Source
+	}
+	retitle(s.Source, entries)
+	toAdd <- entries
+	log.Printf("WebSub pushed %d entries for %s\n", len(entries), s.Topic)
+}
+
+// validSignature checks an X-Hub-Signature, which is method=hexdigest.
+func validSignature(sig, secret string, body []byte) bool {
+	method, digest, ok := strings.Cut(sig, "=")
+	if !ok {
+		return false
+	}
+	var h func() hash.Hash
+	switch method {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	want, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
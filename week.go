@@ -0,0 +1,115 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Week is seven days of entries, each grouped by site like a Daily.
+type Week struct {
+	Start time.Time
+	Base  string
+	Days  []Daily
+}
+
+// isoWeek is week in ISO 8601 form, like 2021-W22.
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// parseISOWeek is the Monday starting an ISO 8601 week, like 2021-W22.
+func parseISOWeek(s string) (time.Time, error) {
+	var year, week int
+	if _, err := fmt.Sscanf(s, "%4d-W%2d", &year, &week); err != nil {
+		return time.Time{}, err
+	}
+	// The first week is the one with the year's first Thursday, so it has January 4th.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, zone)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+	if isoWeek(monday) != s {
+		return time.Time{}, fmt.Errorf("%q isn't a week", s)
+	}
+	return monday, nil
+}
+
+// Prev is the ISO week before the one shown. If what's shown is the last
+// seven days, rather than an ISO week, it's the whole week they started in.
+func (w Week) Prev() string {
+	if w.Start.Weekday() != time.Monday {
+		return isoWeek(w.Start)
+	}
+	return isoWeek(w.Start.AddDate(0, 0, -7))
+}
+
+// Next is the ISO week after the one shown, or empty if that's still to come.
+func (w Week) Next() string {
+	next := w.Start.AddDate(0, 0, 7)
+	if next.After(time.Now()) {
+		return ""
+	}
+	return isoWeek(next)
+}
+
+// showWeek shows the ISO week in the path, like week/2021-W22,
+// or with just week, the last seven days, today included,
+// a day at a time, oldest first.
+func showWeek(w http.ResponseWriter, r *http.Request, fc <-chan []Entry) {
+	start := startOfDay(time.Now().In(zone)).AddDate(0, 0, -6)
+	base := ""
+	if s, ok := strings.CutPrefix(r.URL.Path, "/week/"); ok {
+		var err error
+		start, err = parseISOWeek(s)
+		if err != nil {
+			http.Error(w, "The week must be like 2021-W22.", http.StatusBadRequest)
+			return
+		}
+		start = dayOf(start)
+		if start.After(time.Now()) {
+			http.Error(w, "That week hasn't come yet.", http.StatusNotFound)
+			return
+		}
+		base = "../"
+	}
+
+	feeds := <-fc
+	wk := Week{Start: start, Base: base}
+	for i := 0; i < 7; i++ {
+		day := start.AddDate(0, 0, i)
+		if day.After(time.Now()) {
+			break
+		}
+		wk.Days = append(wk.Days, daily(feeds, day))
+	}
+	weekPage.Execute(w, wk)
+}
+
+var weekPage = newPage("week", weekPageTemplate)
+
+var weekPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+{{with .Base}}	<base href="{{.}}">
+{{end}}	<link rel="icon" href="style/favicon.png">
+	<link rel="stylesheet" href="style/feed.css">
+	<link rel="stylesheet" href="theme.css">
+
+	<title>WEBRSS Week of {{.Start.Format "2 January"}}</title>
+</head>
+
+<body>
+		<nav class="details"><a href="week/{{.Prev}}">←</a> Week of {{.Start.Format "2 January 2006"}}{{with .Next}} <a href="week/{{.}}">→</a>{{end}} · <a href="starred">starred</a> · <a href="search">search</a></nav>
+{{range .Days}}
+		<h2 class="day"><a href="day/{{.Day.Format "2006-01-02"}}">{{.Day.Format "Monday, 2 January"}}</a></h2>
+{{template "cards" .}}
+{{end}}
+</body>
+</html>
+`
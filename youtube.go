@@ -0,0 +1,19 @@
+// © 2021 Steve McCoy. Licensed under the MIT License.
+
+package main
+
+import "net/url"
+
+// YouTube holds the yt: parts of a YouTube channel feed's entries.
+// Their thumbnails and descriptions are in MediaRSS.
+type YouTube struct {
+	VideoID string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+}
+
+// watchURL is the canonical link to the video.
+func (y YouTube) watchURL() string {
+	if y.VideoID == "" {
+		return ""
+	}
+	return "https://www.youtube.com/watch?v=" + url.QueryEscape(y.VideoID)
+}